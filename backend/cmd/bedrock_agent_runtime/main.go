@@ -3,28 +3,179 @@ package main
 import (
 	"aws-s3-knowledge-chatbot/backend/internal/client"
 	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
 	"aws-s3-knowledge-chatbot/backend/internal/handler"
 	"aws-s3-knowledge-chatbot/backend/internal/infrastructure"
+	"aws-s3-knowledge-chatbot/backend/internal/logging"
+	"aws-s3-knowledge-chatbot/backend/internal/metrics"
+	"aws-s3-knowledge-chatbot/backend/internal/tracing"
+	"aws-s3-knowledge-chatbot/backend/internal/transport/http/middleware"
 	"aws-s3-knowledge-chatbot/backend/internal/usecase"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func main() {
 	cfg := config.NewConfigMust()
+	logging.SetupMust(cfg)
+	// AWS クライアント生成より前にトレーサーを登録する
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		panic(err)
+	}
 	bedrockAgentRuntimeClient := client.NewBedrockAgentRuntimeClientMust(cfg)
 	bedrockAgentRuntimeRepository := infrastructure.NewBedrockAgentRuntimeRepository(cfg, bedrockAgentRuntimeClient)
-	bedrockAgentRuntimeUsecase := usecase.NewBedrockAgentRuntimeUsecase(bedrockAgentRuntimeRepository)
-	bh := handler.NewBedrockAgentRuntimeHandler(bedrockAgentRuntimeUsecase)
+	var documentRepository repository.DocumentRepository
+	if cfg.CitationPresignURLs {
+		documentRepository = infrastructure.NewDocumentRepository(cfg, client.NewS3ClientMust(cfg))
+	}
+	bedrockAgentRuntimeUsecase := usecase.NewBedrockAgentRuntimeUsecase(cfg, bedrockAgentRuntimeRepository, documentRepository, newUsageRepositoryMust(cfg))
+	conversationRepository := newConversationRepositoryMust(cfg)
+	conversationUsecase := usecase.NewConversationUsecase(conversationRepository)
+	sessionUsecase := usecase.NewSessionUsecase(cfg, infrastructure.NewSessionRepository(bedrockAgentRuntimeClient), conversationRepository)
+	bh := handler.NewBedrockAgentRuntimeHandler(cfg, bedrockAgentRuntimeUsecase, conversationUsecase, sessionUsecase)
+	ch := handler.NewConversationHandler(conversationUsecase)
+	sh := handler.NewSessionHandler(cfg, sessionUsecase)
 
-	e := gin.Default()
+	e := gin.New()
 	_ = e.SetTrustedProxies(nil)
 
+	e.Use(gin.Recovery(), middleware.RequestID())
+	// 受信した traceparent を引き継いでリクエスト単位のスパンを開始する（ヘルスチェックは除外）
+	e.Use(otelgin.Middleware(cfg.TracingServiceName, otelgin.WithFilter(func(r *http.Request) bool {
+		return r.URL.Path != "/ping"
+	})))
+	e.Use(metrics.Middleware(), middleware.AccessLog())
+
 	// bedrockAgentRuntimeで必須なエンドポイントを設定
 	e.GET("/ping", bh.Ping)
-	e.POST("/invocations", bh.InvokeStream)
 
-	if err := e.Run(cfg.GetAddress()); err != nil {
-		panic(err)
+	// /ping 以外は認証必須（AUTH_ENABLED=true の場合）
+	api := e.Group("/")
+	if cfg.AuthEnabled {
+		api.Use(middleware.Auth(middleware.NewAuthenticatorMust(cfg)))
+	}
+	// Bedrock を呼び出すエンドポイントはレート制限・クォータの対象
+	invoke := api.Group("/")
+	stream := []gin.HandlerFunc{bh.InvokeStream}
+	if cfg.RateLimitEnabled {
+		rateLimitUsecase := usecase.NewRateLimitUsecase(cfg, newRateLimitRepositoryMust(cfg))
+		invoke.Use(middleware.RateLimit(rateLimitUsecase))
+		stream = append([]gin.HandlerFunc{middleware.ConcurrentStreams(rateLimitUsecase)}, stream...)
+	}
+	invoke.POST("/invocations", stream...)
+	// SSE を扱えないクライアント（バッチ・Slack Bot など）向け
+	invoke.POST("/invocations/sync", bh.Invoke)
+	// 回答を生成せず検索結果（チャンク）のみを返す
+	invoke.POST("/search", bh.Search)
+	// 会話履歴（Bedrock 側でセッションが失効した後も参照できる）
+	api.GET("/sessions", ch.ListSessions)
+	api.GET("/sessions/:id/messages", ch.ListMessages)
+	// Bedrock のセッション管理（一覧は会話履歴の GET /sessions と区別する）
+	if cfg.SessionAPIEnabled {
+		api.POST("/sessions", sh.CreateSession)
+		api.GET("/bedrock-sessions", sh.ListSessions)
+		api.GET("/sessions/:id", sh.GetSession)
+		api.POST("/sessions/:id/end", sh.EndSession)
+		api.DELETE("/sessions/:id", sh.DeleteSession)
+	}
+
+	servers := []*http.Server{{Addr: cfg.GetAddress(), Handler: e, ReadHeaderTimeout: 10 * time.Second}}
+	if cfg.MetricsAddr != "" {
+		servers = append(servers, &http.Server{Addr: cfg.MetricsAddr, Handler: metrics.Handler(), ReadHeaderTimeout: 10 * time.Second})
+	}
+	if err := serve(cfg, servers, shutdownTracing); err != nil {
+		slog.Error("server stopped with error", "error", err)
+		os.Exit(1)
+	}
+}
+
+// serve runs the servers until SIGINT/SIGTERM or a listener fails, then drains in-flight requests
+// and flushes buffered spans before returning.
+func serve(cfg *config.Config, servers []*http.Server, shutdownTracing func(context.Context) error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func() {
+			slog.Info("server started", "address", srv.Addr)
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errCh <- fmt.Errorf("serve %s: %w", srv.Addr, err)
+			}
+		}()
+	}
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down", "timeout", cfg.ShutdownTimeout.String())
+	case serveErr = <-errCh:
+	}
+	// 2回目のシグナルで即時終了できるよう、以降はシグナルを既定の動作に戻す
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			// 期限までに終わらないストリームは接続ごと切断する
+			slog.Warn("graceful shutdown timed out", "address", srv.Addr, "error", err)
+			_ = srv.Close()
+		}
+	}
+	// 終了前にバッファ済みのスパンを送信する（待機で期限を使い切っていても送れるよう別の期限にする）
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("flush traces failed", "error", err)
+	}
+	return serveErr
+}
+
+func newConversationRepositoryMust(cfg *config.Config) repository.ConversationRepository {
+	switch cfg.ConversationStore {
+	case config.ConversationStoreFile:
+		r, err := infrastructure.NewConversationFileRepository(cfg.ConversationFileDir)
+		if err != nil {
+			panic(err)
+		}
+		return r
+	case config.ConversationStoreDynamoDB:
+		return infrastructure.NewConversationDynamoDBRepository(cfg, client.NewDynamoDBClientMust(cfg))
+	default:
+		return infrastructure.NewConversationMemoryRepository()
+	}
+}
+
+func newRateLimitRepositoryMust(cfg *config.Config) repository.RateLimitRepository {
+	if cfg.RateLimitStore == config.RateLimitStoreRedis {
+		return infrastructure.NewRateLimitRedisRepository(client.NewRedisClientMust(cfg), cfg.RedisKeyPrefix)
+	}
+	return infrastructure.NewRateLimitMemoryRepository()
+}
+
+func newUsageRepositoryMust(cfg *config.Config) repository.UsageRepository {
+	switch cfg.UsageSink {
+	case config.UsageSinkFile:
+		r, err := infrastructure.NewUsageFileRepository(cfg.UsageFile)
+		if err != nil {
+			panic(err)
+		}
+		return r
+	case config.UsageSinkDynamoDB:
+		return infrastructure.NewUsageDynamoDBRepository(cfg, client.NewDynamoDBClientMust(cfg))
+	default:
+		return infrastructure.NewUsageLogRepository()
 	}
 }
@@ -3,8 +3,9 @@ package main
 import (
 	"aws-s3-knowledge-chatbot/backend/internal/client"
 	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/logging"
 	"context"
-	"log"
+	"log/slog"
 
 	"github.com/aws/aws-lambda-go/lambda"
 )
@@ -13,27 +14,42 @@ func handler(ctx context.Context) error {
 	// 設定読み込み
 	cfg, err := config.NewConfig()
 	if err != nil {
-		log.Println("Failed to load config:", err)
+		slog.ErrorContext(ctx, "failed to load config", "error", err)
+		return err
+	}
+	if _, err := logging.Setup(cfg); err != nil {
 		return err
 	}
 	bedrockAgent, err := client.NewBedrockAgentClient(ctx, cfg)
 	if err != nil {
-		log.Println("Failed to create Bedrock Agent client:", err)
+		slog.ErrorContext(ctx, "failed to create bedrock agent client", "error", err)
 		return err
 	}
+	// レジストリに登録された全データソースを同期する
+	for _, kb := range cfg.KnowledgeBases {
+		for _, dataSourceID := range kb.DataSourceIDs {
+			if err := startIngestionJob(ctx, bedrockAgent, kb.KnowledgeBaseID, dataSourceID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func startIngestionJob(ctx context.Context, bedrockAgent client.BedrockAgentClient, knowledgeBaseID, dataSourceID string) error {
 	// 重複チェック
-	inProgressCount, err := bedrockAgent.InProgressJobCount(ctx, 1)
+	inProgressCount, err := bedrockAgent.InProgressJobCount(ctx, knowledgeBaseID, dataSourceID, 1)
 	if err != nil {
-		log.Println("Failed to get in-progress job count:", err)
+		slog.ErrorContext(ctx, "failed to get in-progress job count", "knowledge_base_id", knowledgeBaseID, "data_source_id", dataSourceID, "error", err)
 		return err
 	}
 	if inProgressCount > 0 {
-		log.Println("Ingestion job is already in progress. Skipping new job start.")
+		slog.InfoContext(ctx, "ingestion job already in progress, skipping", "knowledge_base_id", knowledgeBaseID, "data_source_id", dataSourceID)
 		return nil
 	}
 	// ジョブ開始
-	if err := bedrockAgent.StartIngestionJob(ctx); err != nil {
-		log.Println("Failed to start ingestion job:", err)
+	if err := bedrockAgent.StartIngestionJob(ctx, knowledgeBaseID, dataSourceID); err != nil {
+		slog.ErrorContext(ctx, "failed to start ingestion job", "knowledge_base_id", knowledgeBaseID, "data_source_id", dataSourceID, "error", err)
 		return err
 	}
 	return nil
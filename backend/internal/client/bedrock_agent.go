@@ -3,23 +3,23 @@ package client
 import (
 	"aws-s3-knowledge-chatbot/backend/internal/config"
 	"context"
-	"log"
+	"log/slog"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockagent"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockagent/types"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
 )
 
 type BedrockAgentClient interface {
-	InProgressJobCount(ctx context.Context, limit int32) (int, error)
-	StartIngestionJob(ctx context.Context) error
+	InProgressJobCount(ctx context.Context, knowledgeBaseID, dataSourceID string, limit int32) (int, error)
+	StartIngestionJob(ctx context.Context, knowledgeBaseID, dataSourceID string) error
 }
 
 type bedrockAgentClient struct {
 	client *bedrockagent.Client
-	config *config.Config
 }
 
 func NewBedrockAgentClient(ctx context.Context, conf *config.Config) (BedrockAgentClient, error) {
@@ -27,15 +27,17 @@ func NewBedrockAgentClient(ctx context.Context, conf *config.Config) (BedrockAge
 	if err != nil {
 		return nil, err
 	}
+	otelaws.AppendMiddlewares(&cfg.APIOptions)
+	withSDKLogging(conf, &cfg)
 	return &bedrockAgentClient{
 		client: bedrockagent.NewFromConfig(cfg),
 	}, nil
 }
 
-func (b *bedrockAgentClient) InProgressJobCount(ctx context.Context, limit int32) (int, error) {
+func (b *bedrockAgentClient) InProgressJobCount(ctx context.Context, knowledgeBaseID, dataSourceID string, limit int32) (int, error) {
 	res, err := b.client.ListIngestionJobs(ctx, &bedrockagent.ListIngestionJobsInput{
-		KnowledgeBaseId: aws.String(b.config.KnowledgeBaseID),
-		DataSourceId:    aws.String(b.config.DataSourceID),
+		KnowledgeBaseId: aws.String(knowledgeBaseID),
+		DataSourceId:    aws.String(dataSourceID),
 		MaxResults:      aws.Int32(limit),
 	})
 	if err != nil {
@@ -47,14 +49,14 @@ func (b *bedrockAgentClient) InProgressJobCount(ctx context.Context, limit int32
 	return len(jobs), nil
 }
 
-func (b *bedrockAgentClient) StartIngestionJob(ctx context.Context) error {
+func (b *bedrockAgentClient) StartIngestionJob(ctx context.Context, knowledgeBaseID, dataSourceID string) error {
 	res, err := b.client.StartIngestionJob(ctx, &bedrockagent.StartIngestionJobInput{
-		KnowledgeBaseId: aws.String(b.config.KnowledgeBaseID),
-		DataSourceId:    aws.String(b.config.DataSourceID),
+		KnowledgeBaseId: aws.String(knowledgeBaseID),
+		DataSourceId:    aws.String(dataSourceID),
 	})
 	if err != nil {
 		return err
 	}
-	log.Printf("StartIngestionJob started: %s (status=%s)", lo.FromPtr(res.IngestionJob.IngestionJobId), res.IngestionJob.Status)
+	slog.InfoContext(ctx, "ingestion job started", "ingestion_job_id", lo.FromPtr(res.IngestionJob.IngestionJobId), "status", res.IngestionJob.Status)
 	return nil
 }
@@ -4,12 +4,10 @@ import (
 	"aws-s3-knowledge-chatbot/backend/internal/config"
 	"context"
 	"fmt"
-	"log"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
-	"github.com/aws/smithy-go/logging"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
 )
 
 type BedrockAgentRuntime struct {
@@ -22,10 +20,10 @@ func NewBedrockAgentRuntimeClient(config *config.Config) (*bedrockagentruntime.C
 	if err != nil {
 		return nil, fmt.Errorf("load aws config: %w", err)
 	}
-	ac.ClientLogMode = aws.LogRequest | aws.LogResponse | aws.LogRetries | aws.LogRequestWithBody | aws.LogResponseWithBody
-	return bedrockagentruntime.NewFromConfig(ac, func(o *bedrockagentruntime.Options) {
-		o.Logger = logging.NewStandardLogger(log.Writer())
-	}), nil
+	// AWS SDK の呼び出しごとに子スパンを作る
+	otelaws.AppendMiddlewares(&ac.APIOptions)
+	withSDKLogging(config, &ac)
+	return bedrockagentruntime.NewFromConfig(ac), nil
 }
 
 func NewBedrockAgentRuntimeClientMust(config *config.Config) *bedrockagentruntime.Client {
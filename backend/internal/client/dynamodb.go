@@ -0,0 +1,35 @@
+package client
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"context"
+	"fmt"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+)
+
+func NewDynamoDBClient(config *config.Config) (*dynamodb.Client, error) {
+	ac, err := awsconfig.LoadDefaultConfig(context.Background(), awsconfig.WithRegion(config.AwsRegion))
+	if err != nil {
+		return nil, fmt.Errorf("load aws config: %w", err)
+	}
+	// AWS SDK の呼び出しごとに子スパンを作る
+	otelaws.AppendMiddlewares(&ac.APIOptions)
+	withSDKLogging(config, &ac)
+	return dynamodb.NewFromConfig(ac, func(o *dynamodb.Options) {
+		// DynamoDB Local など互換エンドポイントを使う場合
+		if config.DynamoDBEndpoint != "" {
+			o.BaseEndpoint = &config.DynamoDBEndpoint
+		}
+	}), nil
+}
+
+func NewDynamoDBClientMust(config *config.Config) *dynamodb.Client {
+	client, err := NewDynamoDBClient(config)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
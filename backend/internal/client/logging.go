@@ -0,0 +1,21 @@
+package client
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/logging"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// withSDKLogging routes AWS SDK logs to the structured logger. Request and response
+// bodies contain user prompts and model answers, so they are only logged when enabled.
+func withSDKLogging(config *config.Config, ac *aws.Config) {
+	ac.Logger = logging.SDKLogger()
+	ac.ClientLogMode = aws.LogRetries
+	if config.AwsSDKLogRequests {
+		ac.ClientLogMode |= aws.LogRequest | aws.LogResponse
+	}
+	if config.AwsSDKLogBodies {
+		ac.ClientLogMode |= aws.LogRequestWithBody | aws.LogResponseWithBody
+	}
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func NewRedisClient(config *config.Config) (redis.UniversalClient, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.RedisAddr,
+		Password: config.RedisPassword,
+		DB:       config.RedisDB,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("ping redis: %w", err)
+	}
+	return client, nil
+}
+
+func NewRedisClientMust(config *config.Config) redis.UniversalClient {
+	client, err := NewRedisClient(config)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
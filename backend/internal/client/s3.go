@@ -0,0 +1,30 @@
+package client
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"context"
+	"fmt"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+)
+
+func NewS3Client(config *config.Config) (*s3.Client, error) {
+	ac, err := awsconfig.LoadDefaultConfig(context.Background(), awsconfig.WithRegion(config.AwsRegion))
+	if err != nil {
+		return nil, fmt.Errorf("load aws config: %w", err)
+	}
+	// AWS SDK の呼び出しごとに子スパンを作る
+	otelaws.AppendMiddlewares(&ac.APIOptions)
+	withSDKLogging(config, &ac)
+	return s3.NewFromConfig(ac), nil
+}
+
+func NewS3ClientMust(config *config.Config) *s3.Client {
+	client, err := NewS3Client(config)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
@@ -1,22 +1,177 @@
 package config
 
 import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
 	"fmt"
+	"time"
 
 	"github.com/caarlos0/env/v11"
+	"github.com/samber/lo"
+)
+
+const (
+	ConversationStoreMemory   = "memory"
+	ConversationStoreFile     = "file"
+	ConversationStoreDynamoDB = "dynamodb"
+
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreRedis  = "redis"
+
+	UsageSinkLog      = "log"
+	UsageSinkFile     = "file"
+	UsageSinkDynamoDB = "dynamodb"
 )
 
 type Config struct {
 	AwsRegion       string `env:"AWS_REGION,required"`
-	KnowledgeBaseID string `env:"KNOWLEDGE_BASE_ID,required"`
-	DataSourceID    string `env:"DATA_SOURCE_ID,required"`
-	BedrockModelArn string `env:"BEDROCK_MODEL_ARN,required"`
+	KnowledgeBaseID string `env:"KNOWLEDGE_BASE_ID"`
+	DataSourceID    string `env:"DATA_SOURCE_ID"`
+	BedrockModelArn string `env:"BEDROCK_MODEL_ARN"`
 	Port            int    `env:"PORT" envDefault:"8080"`
+	// Prometheus のスクレイプ用。公開ポートとは分け、内部ネットワークからのみ到達できるようにする（空の場合は無効）
+	MetricsAddr string `env:"METRICS_ADDR" envDefault:":9090"`
+	// SIGTERM 受信後、処理中のリクエスト（ストリーム）の完了を待つ時間。ECS の stopTimeout（既定30秒）より短くする
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"25s"`
+
+	// ナレッジベースのレジストリ（JSON）。KNOWLEDGE_BASE_ID 等の指定は "default" として登録される
+	KnowledgeBasesFile   string                         `env:"KNOWLEDGE_BASES_FILE"`
+	DefaultKnowledgeBase string                         `env:"DEFAULT_KNOWLEDGE_BASE" envDefault:"default"`
+	KnowledgeBases       map[string]model.KnowledgeBase `env:"-"`
+
+	// リクエストで選択できるモデルのエイリアスと推論プロファイルARNの許可リスト
+	// 例: BEDROCK_MODELS="fast=arn:aws:bedrock:...,smart=arn:aws:bedrock:..."
+	BedrockModels map[string]string `env:"BEDROCK_MODELS" envKeyValSeparator:"="`
+
+	// リクエスト毎の検索設定の上限
+	RetrievalMaxResults     int32 `env:"RETRIEVAL_MAX_RESULTS" envDefault:"25"`
+	RetrievalMaxFilterDepth int   `env:"RETRIEVAL_MAX_FILTER_DEPTH" envDefault:"3"`
+
+	// 生成時のプロンプトテンプレート（JSON）と推論パラメータの上限
+	PromptTemplatesFile   string                          `env:"PROMPT_TEMPLATES_FILE"`
+	DefaultPromptTemplate string                          `env:"DEFAULT_PROMPT_TEMPLATE"`
+	GenerationMaxTokens   int32                           `env:"GENERATION_MAX_TOKENS" envDefault:"4096"`
+	PromptTemplates       map[string]model.PromptTemplate `env:"-"`
+
+	// 検索前のクエリ書き換え（オーケストレーション）の既定値
+	OrchestrationQueryDecomposition bool   `env:"ORCHESTRATION_QUERY_DECOMPOSITION" envDefault:"false"`
+	OrchestrationPromptTemplate     string `env:"ORCHESTRATION_PROMPT_TEMPLATE"`
+
+	// 引用元が S3 の場合に署名付きURLを付与する
+	CitationPresignURLs bool          `env:"CITATION_PRESIGN_URLS" envDefault:"false"`
+	CitationPresignTTL  time.Duration `env:"CITATION_PRESIGN_TTL" envDefault:"5m"`
+
+	// Bedrock のセッション管理 API（/sessions の作成・終了・削除、/bedrock-sessions）。
+	// 有効な場合、呼び出しの度にセッションの状態（終了済みか）と所有者を Bedrock に問い合わせる
+	SessionAPIEnabled bool `env:"SESSION_API_ENABLED" envDefault:"false"`
+
+	// 会話履歴の保存先: memory | file | dynamodb
+	ConversationStore     string `env:"CONVERSATION_STORE" envDefault:"memory"`
+	ConversationFileDir   string `env:"CONVERSATION_FILE_DIR" envDefault:"./data/conversations"`
+	ConversationTable     string `env:"CONVERSATION_TABLE"`
+	ConversationUserIndex string `env:"CONVERSATION_USER_INDEX" envDefault:"user_id-index"`
+	// DynamoDB Local など互換エンドポイントを使う場合に指定する
+	DynamoDBEndpoint string `env:"DYNAMODB_ENDPOINT"`
+
+	// Bearer JWT 認証。JWKS は URL または（オフライン検証用に）ローカルファイルから読み込む
+	AuthEnabled     bool          `env:"AUTH_ENABLED" envDefault:"false"`
+	AuthJWKSURL     string        `env:"AUTH_JWKS_URL"`
+	AuthJWKSFile    string        `env:"AUTH_JWKS_FILE"`
+	AuthIssuer      string        `env:"AUTH_ISSUER"`
+	AuthAudience    string        `env:"AUTH_AUDIENCE"`
+	AuthLeeway      time.Duration `env:"AUTH_LEEWAY" envDefault:"30s"`
+	AuthUserClaim   string        `env:"AUTH_USER_CLAIM" envDefault:"sub"`
+	AuthGroupsClaim string        `env:"AUTH_GROUPS_CLAIM" envDefault:"groups"` // Cognito の場合は "cognito:groups"
+	AuthTenantClaim string        `env:"AUTH_TENANT_CLAIM" envDefault:"tenant_id"`
+
+	// 文書単位のアクセス制御。利用者のグループのいずれかをメタデータ（リスト）に含むチャンクのみ検索する
+	AccessFilterEnabled      bool     `env:"ACCESS_FILTER_ENABLED" envDefault:"false"`
+	AccessFilterKey          string   `env:"ACCESS_FILTER_KEY" envDefault:"allowed_groups"`
+	AccessFilterPublicGroups []string `env:"ACCESS_FILTER_PUBLIC_GROUPS" envSeparator:","` // 全員に許可するグループ（例: "public"）
+
+	// /invocations・/search のレート制限（0 の項目は無効）。状態は memory | redis に保持する
+	RateLimitEnabled     bool    `env:"RATE_LIMIT_ENABLED" envDefault:"false"`
+	RateLimitStore       string  `env:"RATE_LIMIT_STORE" envDefault:"memory"`
+	RateLimitIPRate      float64 `env:"RATE_LIMIT_IP_RPS" envDefault:"2"`
+	RateLimitIPBurst     int     `env:"RATE_LIMIT_IP_BURST" envDefault:"20"`
+	RateLimitUserRate    float64 `env:"RATE_LIMIT_USER_RPS" envDefault:"1"`
+	RateLimitUserBurst   int     `env:"RATE_LIMIT_USER_BURST" envDefault:"10"`
+	RateLimitTenantRate  float64 `env:"RATE_LIMIT_TENANT_RPS" envDefault:"0"`
+	RateLimitTenantBurst int     `env:"RATE_LIMIT_TENANT_BURST" envDefault:"0"`
+	MaxConcurrentStreams int     `env:"MAX_CONCURRENT_STREAMS" envDefault:"2"` // 利用者（未認証の場合はIP）毎の同時ストリーム数
+	// 1日（UTC）あたりの入出力トークン数（推定値、CHARACTERS_PER_TOKEN 参照）の上限。応答終了後に精算する
+	QuotaDailyUserTokens   int64 `env:"QUOTA_DAILY_USER_TOKENS" envDefault:"0"`
+	QuotaDailyTenantTokens int64 `env:"QUOTA_DAILY_TENANT_TOKENS" envDefault:"0"`
+
+	// 利用量・コストの記録。トークン数は文字数から推定する
+	ModelPricesFile    string                      `env:"MODEL_PRICES_FILE"`
+	ModelPrices        map[string]model.ModelPrice `env:"-"`
+	CharactersPerToken float64                     `env:"CHARACTERS_PER_TOKEN" envDefault:"3"`
+	UsageSink          string                      `env:"USAGE_SINK" envDefault:"log"` // log | file | dynamodb
+	UsageFile          string                      `env:"USAGE_FILE" envDefault:"./data/usage.jsonl"`
+	UsageTable         string                      `env:"USAGE_TABLE"`
+
+	// Redis 互換ストア（RATE_LIMIT_STORE=redis の場合）
+	RedisAddr      string `env:"REDIS_ADDR" envDefault:"localhost:6379"`
+	RedisPassword  string `env:"REDIS_PASSWORD"`
+	RedisDB        int    `env:"REDIS_DB" envDefault:"0"`
+	RedisKeyPrefix string `env:"REDIS_KEY_PREFIX" envDefault:"chatbot:"`
+
+	// OpenTelemetry トレース: none | otlp | stdout（ローカル確認用。JSON ログと混ざらないよう標準エラーへ出力する）
+	TracingExporter     string  `env:"TRACING_EXPORTER" envDefault:"none"`
+	TracingOTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT"` // 例: http://localhost:4318（未指定時は OTEL_EXPORTER_OTLP_* に従う）
+	TracingServiceName  string  `env:"TRACING_SERVICE_NAME" envDefault:"agent-runtime"`
+	TracingSampleRatio  float64 `env:"TRACING_SAMPLE_RATIO" envDefault:"1"`
+
+	// 構造化ログ（JSON）
+	LogLevel  string `env:"LOG_LEVEL" envDefault:"info"`  // debug | info | warn | error
+	LogRedact bool   `env:"LOG_REDACT" envDefault:"true"` // メールアドレス・トークン等をマスクする
+	// AWS SDK のリクエストログ。本文にはユーザーの質問・回答が含まれるため既定では出力しない
+	AwsSDKLogRequests bool `env:"AWS_SDK_LOG_REQUESTS" envDefault:"false"`
+	AwsSDKLogBodies   bool `env:"AWS_SDK_LOG_BODIES" envDefault:"false"`
+
+	// デバッグ用イベント（message.debug）を SSE に流す。
+	// Bedrock は書き換え後のクエリを返さないため、orchestration_settings には入力クエリと適用した設定のみ含まれる
+	Debug bool `env:"DEBUG" envDefault:"false"`
 }
 
 func NewConfig() (*Config, error) {
 	cfg, err := env.ParseAs[Config]()
-	return &cfg, err
+	if err != nil {
+		return &cfg, err
+	}
+	if err := cfg.loadKnowledgeBases(); err != nil {
+		return &cfg, err
+	}
+	if cfg.PromptTemplatesFile != "" {
+		if cfg.PromptTemplates, err = LoadPromptTemplates(cfg.PromptTemplatesFile); err != nil {
+			return &cfg, err
+		}
+	}
+	if t, ok := cfg.PromptTemplates[cfg.DefaultPromptTemplate]; cfg.DefaultPromptTemplate != "" && (!ok || t.Kind != model.PromptTemplateGeneration) {
+		return &cfg, fmt.Errorf("default prompt template %q is not a defined generation template", cfg.DefaultPromptTemplate)
+	}
+	if t, ok := cfg.PromptTemplates[cfg.OrchestrationPromptTemplate]; cfg.OrchestrationPromptTemplate != "" && (!ok || t.Kind != model.PromptTemplateOrchestration) {
+		return &cfg, fmt.Errorf("orchestration prompt template %q is not a defined orchestration template", cfg.OrchestrationPromptTemplate)
+	}
+	if err := cfg.validateAuth(); err != nil {
+		return &cfg, err
+	}
+	if err := cfg.loadModelPrices(); err != nil {
+		return &cfg, err
+	}
+	if cfg.RateLimitStore != RateLimitStoreMemory && cfg.RateLimitStore != RateLimitStoreRedis {
+		return &cfg, fmt.Errorf("unknown rate limit store %q", cfg.RateLimitStore)
+	}
+	switch cfg.ConversationStore {
+	case ConversationStoreMemory, ConversationStoreFile:
+	case ConversationStoreDynamoDB:
+		if cfg.ConversationTable == "" {
+			return &cfg, fmt.Errorf("CONVERSATION_TABLE is required for the dynamodb conversation store")
+		}
+	default:
+		return &cfg, fmt.Errorf("unknown conversation store %q", cfg.ConversationStore)
+	}
+	return &cfg, nil
 }
 
 func NewConfigMust() *Config {
@@ -30,3 +185,95 @@ func NewConfigMust() *Config {
 func (c *Config) GetAddress() string {
 	return fmt.Sprintf(":%d", c.Port)
 }
+
+func (c *Config) loadModelPrices() error {
+	if c.CharactersPerToken <= 0 {
+		return fmt.Errorf("CHARACTERS_PER_TOKEN must be positive")
+	}
+	switch c.UsageSink {
+	case UsageSinkLog, UsageSinkFile:
+	case UsageSinkDynamoDB:
+		if c.UsageTable == "" {
+			return fmt.Errorf("USAGE_TABLE is required for the dynamodb usage sink")
+		}
+	default:
+		return fmt.Errorf("unknown usage sink %q", c.UsageSink)
+	}
+	if c.ModelPricesFile == "" {
+		return nil
+	}
+	prices, err := LoadModelPrices(c.ModelPricesFile)
+	if err != nil {
+		return err
+	}
+	c.ModelPrices = prices
+	return nil
+}
+
+// GetModelPrice looks up the price of a model ARN, either directly or through its BEDROCK_MODELS alias.
+func (c *Config) GetModelPrice(modelArn string) (model.ModelPrice, bool) {
+	if p, ok := c.ModelPrices[modelArn]; ok {
+		return p, true
+	}
+	for alias, arn := range c.BedrockModels {
+		if arn != modelArn {
+			continue
+		}
+		if p, ok := c.ModelPrices[alias]; ok {
+			return p, true
+		}
+	}
+	return model.ModelPrice{}, false
+}
+
+func (c *Config) validateAuth() error {
+	if !c.AuthEnabled {
+		return nil
+	}
+	if (c.AuthJWKSURL == "") == (c.AuthJWKSFile == "") {
+		return fmt.Errorf("exactly one of AUTH_JWKS_URL or AUTH_JWKS_FILE is required when AUTH_ENABLED")
+	}
+	if c.AuthIssuer == "" || c.AuthAudience == "" {
+		return fmt.Errorf("AUTH_ISSUER and AUTH_AUDIENCE are required when AUTH_ENABLED")
+	}
+	return nil
+}
+
+func (c *Config) loadKnowledgeBases() error {
+	c.KnowledgeBases = map[string]model.KnowledgeBase{}
+	if c.KnowledgeBasesFile != "" {
+		kbs, err := LoadKnowledgeBases(c.KnowledgeBasesFile)
+		if err != nil {
+			return err
+		}
+		c.KnowledgeBases = kbs
+	}
+	if c.KnowledgeBaseID != "" {
+		if _, ok := c.KnowledgeBases["default"]; ok {
+			return fmt.Errorf("knowledge base \"default\" is defined by both KNOWLEDGE_BASE_ID and %s", c.KnowledgeBasesFile)
+		}
+		kb := model.KnowledgeBase{
+			Name:            "default",
+			KnowledgeBaseID: c.KnowledgeBaseID,
+			DataSourceIDs:   lo.Ternary(c.DataSourceID != "", []string{c.DataSourceID}, nil),
+			ModelArn:        c.BedrockModelArn,
+		}
+		if err := kb.Validate(); err != nil {
+			return err
+		}
+		c.KnowledgeBases[kb.Name] = kb
+	}
+	if len(c.KnowledgeBases) == 0 {
+		return fmt.Errorf("no knowledge base configured: set KNOWLEDGE_BASE_ID or KNOWLEDGE_BASES_FILE")
+	}
+	if _, ok := c.KnowledgeBases[c.DefaultKnowledgeBase]; !ok {
+		return fmt.Errorf("default knowledge base %q is not defined", c.DefaultKnowledgeBase)
+	}
+	return nil
+}
+
+// GetKnowledgeBase looks up a registered knowledge base. An empty name selects the default.
+func (c *Config) GetKnowledgeBase(name string) (model.KnowledgeBase, bool) {
+	kb, ok := c.KnowledgeBases[lo.CoalesceOrEmpty(name, c.DefaultKnowledgeBase)]
+	return kb, ok
+}
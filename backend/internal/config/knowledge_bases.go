@@ -0,0 +1,28 @@
+package config
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// LoadKnowledgeBases reads the knowledge base registry from a JSON file keyed by name.
+func LoadKnowledgeBases(path string) (map[string]model.KnowledgeBase, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read knowledge bases: %w", err)
+	}
+	var kbs map[string]model.KnowledgeBase
+	if err := json.Unmarshal(b, &kbs); err != nil {
+		return nil, fmt.Errorf("parse knowledge bases: %w", err)
+	}
+	for name, kb := range kbs {
+		kb.Name = name
+		if err := kb.Validate(); err != nil {
+			return nil, err
+		}
+		kbs[name] = kb
+	}
+	return kbs, nil
+}
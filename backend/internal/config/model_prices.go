@@ -0,0 +1,26 @@
+package config
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// LoadModelPrices reads the price table from a JSON file keyed by model ARN or BEDROCK_MODELS alias.
+func LoadModelPrices(path string) (map[string]model.ModelPrice, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read model prices: %w", err)
+	}
+	var prices map[string]model.ModelPrice
+	if err := json.Unmarshal(b, &prices); err != nil {
+		return nil, fmt.Errorf("parse model prices: %w", err)
+	}
+	for key, p := range prices {
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("model price %q: %w", key, err)
+		}
+	}
+	return prices, nil
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// LoadPromptTemplates reads named prompt templates from a JSON file keyed by template name.
+func LoadPromptTemplates(path string) (map[string]model.PromptTemplate, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read prompt templates: %w", err)
+	}
+	var templates map[string]model.PromptTemplate
+	if err := json.Unmarshal(b, &templates); err != nil {
+		return nil, fmt.Errorf("parse prompt templates: %w", err)
+	}
+	for name, t := range templates {
+		t.Name = name
+		if t.Kind == "" {
+			t.Kind = model.PromptTemplateGeneration
+		}
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+		templates[name] = t
+	}
+	return templates, nil
+}
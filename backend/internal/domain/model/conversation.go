@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+type MessageRole string
+
+const (
+	MessageRoleUser      MessageRole = "user"
+	MessageRoleAssistant MessageRole = "assistant"
+)
+
+// Message is one persisted turn of a conversation.
+type Message struct {
+	ID            string      `json:"id"`
+	SessionID     SessionID   `json:"session_id"`
+	UserID        string      `json:"user_id,omitempty"`
+	Role          MessageRole `json:"role"`
+	Text          string      `json:"text"`
+	Citations     []Citation  `json:"citations,omitempty"`
+	FinishReason  string      `json:"finish_reason,omitempty"`
+	Model         string      `json:"model,omitempty"`
+	KnowledgeBase string      `json:"knowledge_base,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+// Citation is a numbered reference persisted with an assistant message.
+// 署名付きURLは期限切れになるため保存しない
+type Citation struct {
+	Number       int    `json:"number"`
+	Source       string `json:"source,omitempty"`
+	LocationType string `json:"location_type,omitempty"`
+	Text         string `json:"text,omitempty"`
+}
+
+// SessionSummary describes a stored conversation.
+type SessionSummary struct {
+	SessionID    SessionID `json:"session_id"`
+	UserID       string    `json:"user_id,omitempty"`
+	MessageCount int       `json:"message_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "errors"
+
+// 認証・認可や検証の失敗を表すドメインエラー。トランスポート層で HTTP ステータスへ変換する
+var (
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrAccessDenied    = errors.New("access denied")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict") // リソースの状態が要求と合わない（終了済みのセッションなど）
+	ErrInvalidArgument = errors.New("invalid argument")
+)
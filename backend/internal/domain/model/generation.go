@@ -0,0 +1,107 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SearchResultsPlaceholder must appear in every generation prompt template.
+const SearchResultsPlaceholder = "$search_results$"
+
+// maxStopSequences is the upper bound Bedrock accepts for stop sequences.
+const maxStopSequences = 4
+
+type PromptTemplateKind string
+
+const (
+	PromptTemplateGeneration    PromptTemplateKind = "generation"    // 回答生成用（既定）
+	PromptTemplateOrchestration PromptTemplateKind = "orchestration" // クエリ書き換え用
+)
+
+// PromptTemplate is a named prompt template for response generation or query orchestration.
+type PromptTemplate struct {
+	Name      string             `json:"-"`
+	Kind      PromptTemplateKind `json:"kind,omitempty"`
+	Text      string             `json:"text"`
+	Inference *InferenceOptions  `json:"inference,omitempty"` // テンプレート既定の推論パラメータ
+}
+
+// OrchestrationOptions configures how the query is rewritten before retrieval.
+type OrchestrationOptions struct {
+	QueryDecomposition bool
+	PromptTemplate     *PromptTemplate
+	Inference          *InferenceOptions
+}
+
+// InferenceOptions overrides text inference parameters for generation.
+// nil の項目はモデルのデフォルトに従う
+type InferenceOptions struct {
+	Temperature   *float32 `json:"temperature,omitempty"`
+	TopP          *float32 `json:"top_p,omitempty"`
+	MaxTokens     *int32   `json:"max_tokens,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+}
+
+func (t *PromptTemplate) Validate() error {
+	switch t.Kind {
+	case PromptTemplateGeneration, PromptTemplateOrchestration:
+	default:
+		return fmt.Errorf("prompt template %q has unknown kind: %q", t.Name, t.Kind)
+	}
+	if t.Kind == PromptTemplateGeneration && !strings.Contains(t.Text, SearchResultsPlaceholder) {
+		return fmt.Errorf("prompt template %q must include %s", t.Name, SearchResultsPlaceholder)
+	}
+	if t.Inference != nil {
+		if err := t.Inference.Validate(0); err != nil {
+			return fmt.Errorf("prompt template %q: %w", t.Name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks parameter ranges. maxTokens <= 0 disables the max_tokens upper bound.
+func (o *InferenceOptions) Validate(maxTokens int32) error {
+	if o == nil {
+		return nil
+	}
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 1) {
+		return fmt.Errorf("temperature must be between 0 and 1")
+	}
+	if o.TopP != nil && (*o.TopP < 0 || *o.TopP > 1) {
+		return fmt.Errorf("top_p must be between 0 and 1")
+	}
+	if o.MaxTokens != nil && *o.MaxTokens <= 0 {
+		return fmt.Errorf("max_tokens must be positive")
+	}
+	if o.MaxTokens != nil && maxTokens > 0 && *o.MaxTokens > maxTokens {
+		return fmt.Errorf("max_tokens must not exceed %d", maxTokens)
+	}
+	if len(o.StopSequences) > maxStopSequences {
+		return fmt.Errorf("stop_sequences must not exceed %d entries", maxStopSequences)
+	}
+	return nil
+}
+
+// Merge returns a copy of o with the non-nil fields of override applied.
+func (o *InferenceOptions) Merge(override *InferenceOptions) *InferenceOptions {
+	if o == nil {
+		return override
+	}
+	merged := *o
+	if override == nil {
+		return &merged
+	}
+	if override.Temperature != nil {
+		merged.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		merged.TopP = override.TopP
+	}
+	if override.MaxTokens != nil {
+		merged.MaxTokens = override.MaxTokens
+	}
+	if override.StopSequences != nil {
+		merged.StopSequences = override.StopSequences
+	}
+	return &merged
+}
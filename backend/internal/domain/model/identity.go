@@ -0,0 +1,50 @@
+package model
+
+import (
+	"context"
+
+	"github.com/samber/lo"
+)
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	Subject  string   `json:"sub"`
+	TenantID string   `json:"tenant_id,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// Callers returns the names the caller can be matched by in allow-lists: its subject and groups.
+func (i *Identity) Callers() []string {
+	if i == nil {
+		return nil
+	}
+	return lo.Compact(append([]string{i.Subject}, i.Groups...))
+}
+
+// AccessGroups returns the groups whose documents the caller may read: the public groups plus its own.
+func (i *Identity) AccessGroups(public []string) []string {
+	if i == nil {
+		return lo.Uniq(public)
+	}
+	return lo.Uniq(append(append([]string{}, public...), i.Groups...))
+}
+
+// UserID returns the subject, or "" for an anonymous caller.
+func (i *Identity) UserID() string {
+	if i == nil {
+		return ""
+	}
+	return i.Subject
+}
+
+type identityKey struct{}
+
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the authenticated caller, or nil when authentication is disabled.
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey{}).(*Identity)
+	return identity
+}
@@ -0,0 +1,19 @@
+package model
+
+// InvokeOptions holds optional per-request settings for a knowledge base query.
+type InvokeOptions struct {
+	KnowledgeBase  KnowledgeBase
+	ModelArn       string // 空の場合はナレッジベースの既定モデル
+	Retrieval      *RetrievalOptions
+	PromptTemplate *PromptTemplate
+	Inference      *InferenceOptions
+	Orchestration  *OrchestrationOptions
+}
+
+// ResolvedModelArn returns the model used for generation.
+func (o InvokeOptions) ResolvedModelArn() string {
+	if o.ModelArn != "" {
+		return o.ModelArn
+	}
+	return o.KnowledgeBase.ModelArn
+}
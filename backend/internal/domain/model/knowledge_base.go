@@ -0,0 +1,35 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+)
+
+// KnowledgeBase is a registered knowledge base that requests can be routed to.
+type KnowledgeBase struct {
+	Name            string   `json:"-"`
+	KnowledgeBaseID string   `json:"knowledge_base_id"`
+	DataSourceIDs   []string `json:"data_source_ids"`
+	ModelArn        string   `json:"model_arn"`                 // このナレッジベースの既定モデル
+	AllowedCallers  []string `json:"allowed_callers,omitempty"` // 空の場合は全員に許可
+}
+
+func (kb *KnowledgeBase) Validate() error {
+	if kb.KnowledgeBaseID == "" {
+		return fmt.Errorf("knowledge base %q requires knowledge_base_id", kb.Name)
+	}
+	if kb.ModelArn == "" {
+		return fmt.Errorf("knowledge base %q requires model_arn", kb.Name)
+	}
+	return nil
+}
+
+// Allows reports whether any of the caller's identities (user ID or groups) may query this knowledge base.
+func (kb *KnowledgeBase) Allows(callers ...string) bool {
+	if len(kb.AllowedCallers) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(callers, func(c string) bool {
+		return slices.Contains(kb.AllowedCallers, c)
+	})
+}
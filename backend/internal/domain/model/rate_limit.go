@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// RateLimit is a token bucket: Rate tokens are added per second up to Burst.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled reports whether the limit is configured (a zero rate disables it).
+func (l RateLimit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// RateLimitSubject is who a request is accounted to.
+type RateLimitSubject struct {
+	UserID   string
+	TenantID string
+	IP       string
+}
+
+// RateLimitDecision is the outcome of a limit check. RetryAfter is set when the request is denied.
+type RateLimitDecision struct {
+	Allowed    bool
+	RetryAfter time.Duration
+	Reason     string
+}
@@ -0,0 +1,150 @@
+package model
+
+import "fmt"
+
+type SearchType string
+
+const (
+	SearchTypeHybrid   SearchType = "HYBRID"
+	SearchTypeSemantic SearchType = "SEMANTIC"
+)
+
+type FilterOperator string
+
+const (
+	FilterEquals              FilterOperator = "equals"
+	FilterNotEquals           FilterOperator = "not_equals"
+	FilterGreaterThan         FilterOperator = "greater_than"
+	FilterGreaterThanOrEquals FilterOperator = "greater_than_or_equals"
+	FilterLessThan            FilterOperator = "less_than"
+	FilterLessThanOrEquals    FilterOperator = "less_than_or_equals"
+	FilterIn                  FilterOperator = "in"
+	FilterNotIn               FilterOperator = "not_in"
+	FilterStartsWith          FilterOperator = "starts_with"
+	FilterListContains        FilterOperator = "list_contains"
+	FilterStringContains      FilterOperator = "string_contains"
+	FilterAndAll              FilterOperator = "and_all"
+	FilterOrAll               FilterOperator = "or_all"
+)
+
+// MaxFilterGroupSize is the number of filters Bedrock accepts under one and_all / or_all.
+const MaxFilterGroupSize = 5
+
+// RetrievalOptions holds per-request knowledge base retrieval settings.
+// 未指定の項目は Bedrock のデフォルトに従う
+type RetrievalOptions struct {
+	NumberOfResults int32           `json:"number_of_results,omitempty"`
+	SearchType      SearchType      `json:"search_type,omitempty"`
+	Filter          *MetadataFilter `json:"filter,omitempty"`
+}
+
+// MetadataFilter is a filter expression over S3 object metadata.
+// and_all / or_all は Filters に子条件を持ち、それ以外は Key と Value で比較する
+type MetadataFilter struct {
+	Operator FilterOperator   `json:"op"`
+	Key      string           `json:"key,omitempty"`
+	Value    any              `json:"value,omitempty"`
+	Filters  []MetadataFilter `json:"filters,omitempty"`
+}
+
+// RetrievalLimits are server-side bounds applied to RetrievalOptions.
+type RetrievalLimits struct {
+	MaxNumberOfResults int32
+	// MaxFilterDepth bounds the filter sent to Bedrock, including the access filter.
+	MaxFilterDepth int
+	// AccessFilterGroups is the number of groups in the caller's access filter (0 when disabled).
+	AccessFilterGroups int
+}
+
+// AccessFilterDepth returns the levels taken by an access filter over groups: orAll groups hold at
+// most MaxFilterGroupSize members, so every factor of MaxFilterGroupSize adds a level.
+func AccessFilterDepth(groups int) int {
+	depth := 1
+	for capacity := 1; capacity < groups; capacity *= MaxFilterGroupSize {
+		depth++
+	}
+	return depth
+}
+
+func (o *RetrievalOptions) Validate(limits RetrievalLimits) error {
+	maxDepth := limits.MaxFilterDepth
+	if limits.AccessFilterGroups > 0 {
+		depth := AccessFilterDepth(limits.AccessFilterGroups)
+		if o != nil && o.Filter != nil {
+			// 利用者のフィルタはアクセス制御フィルタと and_all で結合される
+			if depth+1 > limits.MaxFilterDepth {
+				return fmt.Errorf("filter cannot be combined with the access filter for %d groups, which needs %d of %d filter levels: %w",
+					limits.AccessFilterGroups, depth+1, limits.MaxFilterDepth, ErrInvalidArgument)
+			}
+			maxDepth--
+		} else if depth > limits.MaxFilterDepth {
+			return fmt.Errorf("access filter for %d groups needs %d filter levels, exceeding the limit of %d: %w",
+				limits.AccessFilterGroups, depth, limits.MaxFilterDepth, ErrInvalidArgument)
+		}
+	}
+	if o == nil {
+		return nil
+	}
+	if o.NumberOfResults < 0 || o.NumberOfResults > limits.MaxNumberOfResults {
+		return fmt.Errorf("number_of_results must be between 1 and %d", limits.MaxNumberOfResults)
+	}
+	if o.SearchType != "" && o.SearchType != SearchTypeHybrid && o.SearchType != SearchTypeSemantic {
+		return fmt.Errorf("search_type must be %s or %s", SearchTypeHybrid, SearchTypeSemantic)
+	}
+	if o.Filter != nil {
+		return o.Filter.validate(1, maxDepth)
+	}
+	return nil
+}
+
+func (f *MetadataFilter) validate(depth, maxDepth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("filter nesting must not exceed %d levels", maxDepth)
+	}
+	switch f.Operator {
+	case FilterAndAll, FilterOrAll:
+		if len(f.Filters) < 2 || len(f.Filters) > MaxFilterGroupSize {
+			return fmt.Errorf("%s filter requires between 2 and %d filters", f.Operator, MaxFilterGroupSize)
+		}
+		for i := range f.Filters {
+			if err := f.Filters[i].validate(depth+1, maxDepth); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FilterIn, FilterNotIn:
+		if _, ok := f.Value.([]any); !ok {
+			return fmt.Errorf("%s filter requires an array value", f.Operator)
+		}
+	case FilterEquals, FilterNotEquals, FilterGreaterThan, FilterGreaterThanOrEquals,
+		FilterLessThan, FilterLessThanOrEquals, FilterStartsWith, FilterListContains, FilterStringContains:
+	default:
+		return fmt.Errorf("unknown filter operator: %q", f.Operator)
+	}
+	if f.Key == "" {
+		return fmt.Errorf("%s filter requires a key", f.Operator)
+	}
+	if f.Value == nil {
+		return fmt.Errorf("%s filter requires a value", f.Operator)
+	}
+	if len(f.Filters) > 0 {
+		return fmt.Errorf("%s filter must not have nested filters", f.Operator)
+	}
+	return nil
+}
+
+// Depth returns the number of levels in the filter tree; a single condition has depth 1.
+func (f *MetadataFilter) Depth() int {
+	depth := 0
+	for i := range f.Filters {
+		depth = max(depth, f.Filters[i].Depth())
+	}
+	return depth + 1
+}
+
+// SearchOptions holds settings for a retrieve-only knowledge base query.
+type SearchOptions struct {
+	KnowledgeBase KnowledgeBase
+	Retrieval     *RetrievalOptions
+	NextToken     string // 前ページの結果で返されたページングトークン
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Bedrock のセッションIDとして受け付ける形式
+var sessionIDPattern = regexp.MustCompile(`^[0-9a-zA-Z._:-]{1,100}$`)
+
+// SessionID identifies a Bedrock Agent Runtime session. The zero value means "start a new session".
+type SessionID string
+
+func (id SessionID) String() string {
+	return string(id)
+}
+
+func (id SessionID) IsZero() bool {
+	return id == ""
+}
+
+func (id SessionID) Validate() error {
+	if id.IsZero() || sessionIDPattern.MatchString(string(id)) {
+		return nil
+	}
+	return fmt.Errorf("invalid session_id: %q", string(id))
+}
+
+type SessionStatus string
+
+const (
+	SessionStatusActive  SessionStatus = "ACTIVE"
+	SessionStatusExpired SessionStatus = "EXPIRED"
+	SessionStatusEnded   SessionStatus = "ENDED"
+)
+
+// Bedrock のセッションメタデータに保存するキー
+const (
+	SessionMetadataUserID        = "user_id"
+	SessionMetadataTenantID      = "tenant_id"
+	SessionMetadataKnowledgeBase = "knowledge_base"
+)
+
+// Session is a Bedrock-managed conversation session and who it belongs to.
+type Session struct {
+	ID            SessionID     `json:"session_id"`
+	Arn           string        `json:"session_arn,omitempty"`
+	Status        SessionStatus `json:"status,omitempty"`
+	UserID        string        `json:"user_id,omitempty"`
+	TenantID      string        `json:"tenant_id,omitempty"`
+	KnowledgeBase string        `json:"knowledge_base,omitempty"`
+	CreatedAt     time.Time     `json:"created_at,omitzero"`
+	UpdatedAt     time.Time     `json:"updated_at,omitzero"`
+}
+
+// Metadata returns the attributes stored as Bedrock session metadata.
+func (s Session) Metadata() map[string]string {
+	m := map[string]string{}
+	for k, v := range map[string]string{
+		SessionMetadataUserID:        s.UserID,
+		SessionMetadataTenantID:      s.TenantID,
+		SessionMetadataKnowledgeBase: s.KnowledgeBase,
+	} {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// SetMetadata fills the attributes from Bedrock session metadata.
+func (s *Session) SetMetadata(m map[string]string) {
+	s.UserID = m[SessionMetadataUserID]
+	s.TenantID = m[SessionMetadataTenantID]
+	s.KnowledgeBase = m[SessionMetadataKnowledgeBase]
+}
+
+// ListSessionsOptions pages through sessions. A non-empty UserID keeps only that user's sessions.
+type ListSessionsOptions struct {
+	UserID     string
+	MaxResults int32
+	NextToken  string
+}
+
+type SessionPage struct {
+	Sessions  []Session `json:"sessions"`
+	NextToken string    `json:"next_token,omitempty"`
+}
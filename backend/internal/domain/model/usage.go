@@ -0,0 +1,62 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Usage is the consumption of one invocation. RetrieveAndGenerate does not report token counts,
+// so tokens are estimated from character counts.
+type Usage struct {
+	InputCharacters  int64   `json:"input_characters"`
+	OutputCharacters int64   `json:"output_characters"`
+	InputTokens      int64   `json:"input_tokens"`
+	OutputTokens     int64   `json:"output_tokens"`
+	Cost             float64 `json:"cost"`
+	Currency         string  `json:"currency,omitempty"`
+}
+
+func (u Usage) TotalTokens() int64 {
+	return u.InputTokens + u.OutputTokens
+}
+
+// ModelPrice is the on-demand price of a model.
+type ModelPrice struct {
+	InputPer1KTokens  float64 `json:"input_per_1k_tokens"`
+	OutputPer1KTokens float64 `json:"output_per_1k_tokens"`
+	Currency          string  `json:"currency"`
+}
+
+func (p ModelPrice) Validate() error {
+	if p.InputPer1KTokens < 0 || p.OutputPer1KTokens < 0 {
+		return fmt.Errorf("prices must not be negative")
+	}
+	return nil
+}
+
+// NewUsage estimates tokens from character counts and prices them. charactersPerToken must be positive.
+func NewUsage(inputCharacters, outputCharacters int64, charactersPerToken float64, price ModelPrice) Usage {
+	u := Usage{
+		InputCharacters:  inputCharacters,
+		OutputCharacters: outputCharacters,
+		InputTokens:      int64(math.Ceil(float64(inputCharacters) / charactersPerToken)),
+		OutputTokens:     int64(math.Ceil(float64(outputCharacters) / charactersPerToken)),
+		Currency:         price.Currency,
+	}
+	u.Cost = float64(u.InputTokens)/1000*price.InputPer1KTokens + float64(u.OutputTokens)/1000*price.OutputPer1KTokens
+	return u
+}
+
+// UsageRecord attributes the usage of one invocation for chargeback.
+type UsageRecord struct {
+	MessageID     string    `json:"message_id"`
+	SessionID     SessionID `json:"session_id,omitempty"`
+	UserID        string    `json:"user_id,omitempty"`
+	TenantID      string    `json:"tenant_id,omitempty"`
+	Model         string    `json:"model"`
+	KnowledgeBase string    `json:"knowledge_base,omitempty"`
+	FinishReason  string    `json:"finish_reason"`
+	Usage
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -1,12 +1,21 @@
 package repository
 
 import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
 	"context"
 
 	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
 )
 
 type BedrockAgentRuntimeRepository interface {
-	RetrieveAndGenerate(ctx context.Context, sessionID, inputText string) (*bedrockagentruntime.RetrieveAndGenerateOutput, error)
-	RetrieveAndGenerateStream(ctx context.Context, sessionID, inputText string) (*bedrockagentruntime.RetrieveAndGenerateStreamOutput, error)
+	Retrieve(ctx context.Context, query string, opts model.SearchOptions) (*bedrockagentruntime.RetrieveOutput, error)
+	RetrieveAndGenerate(ctx context.Context, sessionID model.SessionID, inputText string, opts model.InvokeOptions) (*bedrockagentruntime.RetrieveAndGenerateOutput, error)
+	RetrieveAndGenerateStream(ctx context.Context, sessionID model.SessionID, inputText string, opts model.InvokeOptions) (*RetrieveAndGenerateStreamOutput, error)
+}
+
+// RetrieveAndGenerateStreamOutput is an opened answer stream. The SDK output keeps its
+// event stream unexported, so the reader is exposed as an interface that tests can fake.
+type RetrieveAndGenerateStreamOutput struct {
+	SessionID *string
+	Stream    bedrockagentruntime.RetrieveAndGenerateStreamResponseOutputReader
 }
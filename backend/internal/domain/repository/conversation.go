@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"context"
+)
+
+type ConversationRepository interface {
+	AppendMessages(ctx context.Context, messages ...model.Message) error
+	// ListMessages returns the messages of a session in chronological order.
+	ListMessages(ctx context.Context, sessionID model.SessionID) ([]model.Message, error)
+	// DeleteMessages removes every stored message of a session.
+	DeleteMessages(ctx context.Context, sessionID model.SessionID) error
+	// ListSessions returns stored sessions, newest first. An empty userID lists every session.
+	ListSessions(ctx context.Context, userID string) ([]model.SessionSummary, error)
+}
@@ -0,0 +1,8 @@
+package repository
+
+import "context"
+
+type DocumentRepository interface {
+	// PresignURL returns a short-lived HTTPS URL for an s3:// document URI.
+	PresignURL(ctx context.Context, uri string) (string, error)
+}
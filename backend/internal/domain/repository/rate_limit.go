@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"context"
+	"time"
+)
+
+// RateLimitRepository holds rate limiting state shared between server instances.
+type RateLimitRepository interface {
+	// Take consumes one token from the bucket. When the bucket is empty it returns false and the wait until the next token.
+	Take(ctx context.Context, key string, limit model.RateLimit) (bool, time.Duration, error)
+	// Acquire increments a concurrency counter unless it has reached max. ttl bounds how long a slot
+	// survives if Release is never called (e.g. the process crashed).
+	Acquire(ctx context.Context, key string, max int, ttl time.Duration) (bool, error)
+	Release(ctx context.Context, key string) error
+	// Usage returns the amount recorded for key.
+	Usage(ctx context.Context, key string) (int64, error)
+	// AddUsage adds amount to key, which expires after ttl, and returns the new total.
+	AddUsage(ctx context.Context, key string, amount int64, ttl time.Duration) (int64, error)
+}
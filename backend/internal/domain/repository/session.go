@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"context"
+)
+
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session model.Session) (*model.Session, error)
+	GetSession(ctx context.Context, id model.SessionID) (*model.Session, error)
+	EndSession(ctx context.Context, id model.SessionID) (*model.Session, error)
+	DeleteSession(ctx context.Context, id model.SessionID) error
+	ListSessions(ctx context.Context, opts model.ListSessionsOptions) (*model.SessionPage, error)
+}
@@ -0,0 +1,11 @@
+package repository
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"context"
+)
+
+// UsageRepository is a sink for per-invocation usage records.
+type UsageRepository interface {
+	Record(ctx context.Context, record model.UsageRecord) error
+}
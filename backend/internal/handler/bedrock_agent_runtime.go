@@ -1,33 +1,215 @@
 package handler
 
 import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/logging"
+	"aws-s3-knowledge-chatbot/backend/internal/metrics"
+	"aws-s3-knowledge-chatbot/backend/internal/transport/http/middleware"
 	"aws-s3-knowledge-chatbot/backend/internal/transport/http/sse"
 	"aws-s3-knowledge-chatbot/backend/internal/usecase"
 	"context"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/oklog/ulid/v2"
+	"github.com/samber/lo"
 )
 
 type BedrockAgentRuntimeHandler interface {
 	Ping(ctx *gin.Context)
+	Search(ctx *gin.Context)
+	Invoke(ctx *gin.Context)
 	InvokeStream(ctx *gin.Context)
 }
 
 type bedrockAgentRuntimeHandler struct {
+	config                     *config.Config
 	bedrockAgentRuntimeUsecase usecase.BedrockAgentRuntimeUsecase
+	conversationUsecase        usecase.ConversationUsecase
+	sessionUsecase             usecase.SessionUsecase
 }
 
-func NewBedrockAgentRuntimeHandler(bedrockAgentRuntimeUsecase usecase.BedrockAgentRuntimeUsecase) BedrockAgentRuntimeHandler {
+func NewBedrockAgentRuntimeHandler(
+	config *config.Config,
+	bedrockAgentRuntimeUsecase usecase.BedrockAgentRuntimeUsecase,
+	conversationUsecase usecase.ConversationUsecase,
+	sessionUsecase usecase.SessionUsecase,
+) BedrockAgentRuntimeHandler {
 	return &bedrockAgentRuntimeHandler{
+		config:                     config,
 		bedrockAgentRuntimeUsecase: bedrockAgentRuntimeUsecase,
+		conversationUsecase:        conversationUsecase,
+		sessionUsecase:             sessionUsecase,
 	}
 }
 
+type invokeRequest struct {
+	SessionID      model.SessionID         `json:"session_id"`
+	Query          string                  `json:"query" binding:"required"`
+	KnowledgeBase  string                  `json:"knowledge_base"`
+	Model          string                  `json:"model"` // モデルのエイリアス（例: "fast"）
+	Retrieval      *model.RetrievalOptions `json:"retrieval"`
+	PromptTemplate string                  `json:"prompt_template"`
+	Inference      *model.InferenceOptions `json:"inference"`
+	Orchestration  *orchestrationRequest   `json:"orchestration"`
+
+	options model.InvokeOptions
+	// 受信時点で採番したユーザー発話（履歴保存用）
+	message model.Message
+}
+
+type orchestrationRequest struct {
+	QueryDecomposition *bool                   `json:"query_decomposition"`
+	PromptTemplate     string                  `json:"prompt_template"`
+	Inference          *model.InferenceOptions `json:"inference"`
+}
+
+// bindInvokeRequest parses and validates the request body, writing an error response on failure.
+func (h *bedrockAgentRuntimeHandler) bindInvokeRequest(c *gin.Context) (*invokeRequest, bool) {
+	var r invokeRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err := r.SessionID.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if !r.SessionID.IsZero() {
+		c.Request = c.Request.WithContext(logging.WithSessionID(c.Request.Context(), r.SessionID))
+	}
+	identity := model.IdentityFromContext(c.Request.Context())
+	opts, err := h.resolveInvokeOptions(identity, &r)
+	if err != nil {
+		respondInvalid(c, err)
+		return nil, false
+	}
+	r.options = opts
+	// 終了済みのセッションや他の利用者のセッションは継続させない
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	if err := h.sessionUsecase.ContinueSession(ctx, r.SessionID); err != nil {
+		slog.WarnContext(ctx, "session cannot be continued", "error", err)
+		respondError(c, err)
+		return nil, false
+	}
+	r.message = model.Message{
+		ID:            ulid.Make().String(),
+		UserID:        identity.UserID(),
+		Role:          model.MessageRoleUser,
+		Text:          r.Query,
+		KnowledgeBase: opts.KnowledgeBase.Name,
+		CreatedAt:     time.Now(),
+	}
+	return &r, true
+}
+
+func (h *bedrockAgentRuntimeHandler) resolveInvokeOptions(identity *model.Identity, r *invokeRequest) (model.InvokeOptions, error) {
+	opts := model.InvokeOptions{
+		Retrieval: r.Retrieval,
+		Inference: r.Inference,
+	}
+	kb, err := h.resolveRetrieval(identity, r.KnowledgeBase, r.Retrieval)
+	if err != nil {
+		return opts, err
+	}
+	opts.KnowledgeBase = kb
+	if r.Model != "" {
+		arn, ok := h.config.BedrockModels[r.Model]
+		if !ok {
+			return opts, fmt.Errorf("unknown model: %q", r.Model)
+		}
+		opts.ModelArn = arn
+	}
+	if err := r.Inference.Validate(h.config.GenerationMaxTokens); err != nil {
+		return opts, err
+	}
+	if name := lo.CoalesceOrEmpty(r.PromptTemplate, h.config.DefaultPromptTemplate); name != "" {
+		t, err := h.lookupPromptTemplate(name, model.PromptTemplateGeneration)
+		if err != nil {
+			return opts, err
+		}
+		// テンプレート既定値にリクエストの指定を上書きする
+		opts.PromptTemplate = t
+		opts.Inference = t.Inference.Merge(r.Inference)
+	}
+
+	orchestration, err := h.resolveOrchestration(r.Orchestration)
+	if err != nil {
+		return opts, err
+	}
+	opts.Orchestration = orchestration
+	return opts, nil
+}
+
+// resolveRetrieval looks up the knowledge base the caller may query and validates retrieval settings
+// against server-side limits.
+func (h *bedrockAgentRuntimeHandler) resolveRetrieval(identity *model.Identity, name string, retrieval *model.RetrievalOptions) (model.KnowledgeBase, error) {
+	kb, ok := h.config.GetKnowledgeBase(name)
+	if !ok {
+		return kb, fmt.Errorf("unknown knowledge_base: %q", name)
+	}
+	if !kb.Allows(identity.Callers()...) {
+		return kb, fmt.Errorf("knowledge base %q: %w", kb.Name, model.ErrAccessDenied)
+	}
+	limits := model.RetrievalLimits{
+		MaxNumberOfResults: h.config.RetrievalMaxResults,
+		MaxFilterDepth:     h.config.RetrievalMaxFilterDepth,
+	}
+	if h.config.AccessFilterEnabled {
+		limits.AccessFilterGroups = len(identity.AccessGroups(h.config.AccessFilterPublicGroups))
+	}
+	if err := retrieval.Validate(limits); err != nil {
+		return kb, err
+	}
+	return kb, nil
+}
+
+// resolveOrchestration applies per-request overrides on top of the deployment defaults.
+func (h *bedrockAgentRuntimeHandler) resolveOrchestration(r *orchestrationRequest) (*model.OrchestrationOptions, error) {
+	o := model.OrchestrationOptions{QueryDecomposition: h.config.OrchestrationQueryDecomposition}
+	name := h.config.OrchestrationPromptTemplate
+	var inference *model.InferenceOptions
+	if r != nil {
+		if err := r.Inference.Validate(h.config.GenerationMaxTokens); err != nil {
+			return nil, fmt.Errorf("orchestration: %w", err)
+		}
+		if r.QueryDecomposition != nil {
+			o.QueryDecomposition = *r.QueryDecomposition
+		}
+		name = lo.CoalesceOrEmpty(r.PromptTemplate, name)
+		inference = r.Inference
+	}
+	o.Inference = inference
+	if name != "" {
+		t, err := h.lookupPromptTemplate(name, model.PromptTemplateOrchestration)
+		if err != nil {
+			return nil, fmt.Errorf("orchestration: %w", err)
+		}
+		o.PromptTemplate = t
+		o.Inference = t.Inference.Merge(inference)
+	}
+	if !o.QueryDecomposition && o.PromptTemplate == nil && o.Inference == nil {
+		return nil, nil
+	}
+	return &o, nil
+}
+
+func (h *bedrockAgentRuntimeHandler) lookupPromptTemplate(name string, kind model.PromptTemplateKind) (*model.PromptTemplate, error) {
+	t, ok := h.config.PromptTemplates[name]
+	if !ok || t.Kind != kind {
+		return nil, fmt.Errorf("unknown prompt_template: %q", name)
+	}
+	return &t, nil
+}
+
 func (h *bedrockAgentRuntimeHandler) Ping(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":    "ok",
@@ -35,20 +217,71 @@ func (h *bedrockAgentRuntimeHandler) Ping(c *gin.Context) {
 	})
 }
 
-func (h *bedrockAgentRuntimeHandler) InvokeStream(c *gin.Context) {
+func (h *bedrockAgentRuntimeHandler) Search(c *gin.Context) {
 	type req struct {
-		SessionID string `json:"session_id"`
-		Query     string `json:"query" binding:"required"`
+		Query         string                  `json:"query" binding:"required"`
+		KnowledgeBase string                  `json:"knowledge_base"`
+		Retrieval     *model.RetrievalOptions `json:"retrieval"`
+		NextToken     string                  `json:"next_token"`
 	}
 	var r req
 	if err := c.ShouldBindJSON(&r); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	kb, err := h.resolveRetrieval(model.IdentityFromContext(c.Request.Context()), r.KnowledgeBase, r.Retrieval)
+	if err != nil {
+		respondInvalid(c, err)
+		return
+	}
 
-	em := sse.NewEmitter(c)
-	stopHeartbeat := em.StartHeartbeat(10 * time.Second)
-	defer stopHeartbeat()
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	res, err := h.bedrockAgentRuntimeUsecase.Search(ctx, r.Query, model.SearchOptions{
+		KnowledgeBase: kb,
+		Retrieval:     r.Retrieval,
+		NextToken:     r.NextToken,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "search failed", "error", err)
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *bedrockAgentRuntimeHandler) Invoke(c *gin.Context) {
+	r, ok := h.bindInvokeRequest(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	res, err := h.bedrockAgentRuntimeUsecase.Invoke(ctx, r.SessionID, r.Query, r.options)
+	if err != nil {
+		slog.ErrorContext(ctx, "invoke failed", "error", err)
+		respondError(c, err)
+		return
+	}
+	c.Set(middleware.UsageTokensKey, res.Usage.TotalTokens())
+	h.saveTurn(c.Request.Context(), r, res.SessionID, model.Message{
+		Role:         model.MessageRoleAssistant,
+		Text:         res.Text,
+		Citations:    lo.Map(res.References, toMessageCitation),
+		FinishReason: string(res.FinishReason),
+		Model:        res.Model,
+	})
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *bedrockAgentRuntimeHandler) InvokeStream(c *gin.Context) {
+	r, ok := h.bindInvokeRequest(c)
+	if !ok {
+		return
+	}
 
 	reqCtx := c.Request.Context()
 	srvCtx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
@@ -66,47 +299,141 @@ func (h *bedrockAgentRuntimeHandler) InvokeStream(c *gin.Context) {
 		}
 	}()
 
-	ch, err := h.bedrockAgentRuntimeUsecase.InvokeStream(ctx, r.SessionID, r.Query)
+	ch, err := h.bedrockAgentRuntimeUsecase.InvokeStream(ctx, r.SessionID, r.Query, r.options)
+	em := sse.NewEmitter(c)
 	if err != nil {
-		_ = em.EmitError(err.Error(), sse.WithSessionID(r.SessionID))
+		// ストリーム開始前のエラーはステータスコードにも反映する
+		slog.ErrorContext(ctx, "invoke stream failed", "error", err)
+		info := sse.ClassifyError(err)
+		c.Status(info.HTTPStatus)
+		_ = em.EmitErrorInfo(info, sse.WithSessionID(r.SessionID.String()))
 		return
 	}
+	metrics.ActiveStreams.Inc()
+	defer metrics.ActiveStreams.Dec()
+	stopHeartbeat := em.StartHeartbeat(10 * time.Second)
+	defer stopHeartbeat()
 
+	messageID := ulid.Make().String()
+	sessionID := r.SessionID.String()
 	opts := []sse.EventOption{
-		sse.WithID(ulid.Make().String()),
-		sse.WithSessionID(r.SessionID),
+		sse.WithID(messageID),
+		sse.WithSessionID(sessionID),
+	}
+
+	// 保存用にアシスタントの返答を組み立てる
+	reply := model.Message{
+		Role:  model.MessageRoleAssistant,
+		Model: r.options.ResolvedModelArn(),
+	}
+	var text strings.Builder
+	var usage *model.Usage
+	defer func() {
+		reply.ID = messageID
+		reply.Text = text.String()
+		c.Set(middleware.UsageTokensKey, h.usageTokens(r.Query, reply.Text, usage))
+		h.saveTurn(reqCtx, r, model.SessionID(sessionID), reply)
+	}()
+
+	// usecase から message.end が届かずに終了した場合の終端イベント
+	emitEnd := func(reason sse.AIEventFinishReason) {
+		end := sse.NewAIMessageEnd(reason, opts...)
+		end.Model = r.options.ResolvedModelArn()
+		reply.FinishReason = string(reason)
+		_ = em.Emit(string(sse.EventMessageEnd), end, opts...)
 	}
 
 	c.Stream(func(_ io.Writer) bool {
 		select {
 		case <-ctx.Done():
-			_ = em.EmitMessageEnd(sse.FinishError, opts...)
+			if reqCtx.Err() != nil {
+				metrics.ClientDisconnects.Inc()
+			}
+			_ = em.EmitErrorInfo(sse.ClassifyError(context.Cause(ctx)), opts...)
+			emitEnd(sse.FinishError)
 			return false
 
 		case evt, ok := <-ch:
 			if !ok {
-				_ = em.EmitMessageEnd(sse.FinishCompleted, opts...)
+				emitEnd(sse.FinishCompleted)
 				return false
 			}
 
+			// usecase が採番したメッセージID / Bedrock 採番のセッションIDを優先する
+			if base := evt.GetBase(); base.ID != "" || base.SessionID != "" {
+				messageID = lo.CoalesceOrEmpty(base.ID, messageID)
+				sessionID = lo.CoalesceOrEmpty(base.SessionID, sessionID)
+				opts = []sse.EventOption{
+					sse.WithID(messageID),
+					sse.WithSessionID(sessionID),
+				}
+			}
+
 			switch e := evt.(type) {
 			case sse.AIMessageStart:
-				_ = em.EmitMessageStart(e.Message.Role, opts...)
+				_ = em.EmitMessageStartWithHeader(e.Message, opts...)
 			case sse.AIMessageDelta:
+				text.WriteString(e.Delta)
 				_ = em.EmitMessageDelta(e.Delta, opts...)
 			case sse.AIMessageCitation:
-				_ = em.EmitMessageCitation(e.Refs, opts...)
-				return false
+				reply.Citations = append(reply.Citations, lo.Map(e.Refs, toMessageCitation)...)
+				// Bedrock は本文と引用を交互に返すため、引用後もストリームを継続する
+				_ = em.Emit(string(sse.EventMessageCitation), e, opts...)
+			case sse.AIMessageGuardrail:
+				_ = em.EmitMessageGuardrail(e.Action, e.Assessment, opts...)
+			case sse.AIMessageDebug:
+				_ = em.EmitMessageDebug(e.Name, e.Data, opts...)
 			case sse.AIMessageEnd:
-				_ = em.EmitMessageEnd(e.FinishReason, opts...)
+				reply.FinishReason = string(e.FinishReason)
+				usage = e.Usage
+				_ = em.Emit(string(sse.EventMessageEnd), e, opts...)
 				return false
 			case sse.AIError:
-				_ = em.EmitError(e.Message, opts...)
-				return false
+				// Code / Retryable を保持したまま転送し、続く message.end で終了する
+				_ = em.Emit(string(sse.EventError), e, opts...)
 			default:
-				log.Printf("[sse] unknown event type: %T\n", e)
+				slog.WarnContext(ctx, "unknown stream event", "type", fmt.Sprintf("%T", e))
 			}
 			return true
 		}
 	})
 }
+
+// saveTurn persists the user's query and the assistant's reply. Failures are logged, not returned,
+// so that a history store outage never breaks answering.
+func (h *bedrockAgentRuntimeHandler) saveTurn(ctx context.Context, r *invokeRequest, sessionID model.SessionID, reply model.Message) {
+	if sessionID.IsZero() {
+		return
+	}
+	// クライアント切断後も保存できるようにキャンセルを切り離す
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	query := r.message
+	query.SessionID = sessionID
+	reply.SessionID = sessionID
+	reply.UserID = query.UserID
+	reply.KnowledgeBase = query.KnowledgeBase
+	if err := h.conversationUsecase.SaveMessages(ctx, query, reply); err != nil {
+		slog.ErrorContext(ctx, "save conversation failed", "session", sessionID, "error", err)
+	}
+}
+
+// usageTokens returns the tokens settled against the daily quotas: the usecase's estimate, or when the
+// stream ended without one (e.g. the client disconnected), an estimate from the query and the partial answer.
+func (h *bedrockAgentRuntimeHandler) usageTokens(query, answer string, usage *model.Usage) int64 {
+	if usage != nil {
+		return usage.TotalTokens()
+	}
+	return model.NewUsage(int64(utf8.RuneCountInString(query)), int64(utf8.RuneCountInString(answer)),
+		h.config.CharactersPerToken, model.ModelPrice{}).TotalTokens()
+}
+
+func toMessageCitation(ref sse.CitationReference, _ int) model.Citation {
+	return model.Citation{
+		Number:       ref.Number,
+		Source:       ref.Source,
+		LocationType: ref.LocationType,
+		Text:         ref.Text,
+	}
+}
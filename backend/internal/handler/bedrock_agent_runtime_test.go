@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/transport/http/middleware"
+	"aws-s3-knowledge-chatbot/backend/internal/transport/http/sse"
+	"aws-s3-knowledge-chatbot/backend/internal/usecase"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeBedrockAgentRuntimeUsecase struct {
+	usecase.BedrockAgentRuntimeUsecase
+	events []sse.AIEvent
+	result *usecase.InvokeResult
+}
+
+func (u fakeBedrockAgentRuntimeUsecase) Invoke(context.Context, model.SessionID, string, model.InvokeOptions) (*usecase.InvokeResult, error) {
+	return u.result, nil
+}
+
+func (u fakeBedrockAgentRuntimeUsecase) InvokeStream(context.Context, model.SessionID, string, model.InvokeOptions) (<-chan sse.AIEvent, error) {
+	ch := make(chan sse.AIEvent, len(u.events))
+	for _, ev := range u.events {
+		ch <- ev
+	}
+	close(ch)
+	return ch, nil
+}
+
+// fakeRateLimitUsecase allows every request and reports the tokens settled after it.
+type fakeRateLimitUsecase struct {
+	usecase.RateLimitUsecase
+	settled chan int64
+}
+
+func (fakeRateLimitUsecase) Allow(context.Context, model.RateLimitSubject) (model.RateLimitDecision, error) {
+	return model.RateLimitDecision{Allowed: true}, nil
+}
+
+func (u fakeRateLimitUsecase) SettleUsage(_ context.Context, _ model.RateLimitSubject, tokens int64) error {
+	u.settled <- tokens
+	return nil
+}
+
+func (fakeBedrockAgentRuntimeUsecase) Search(context.Context, string, model.SearchOptions) (*usecase.SearchResult, error) {
+	return &usecase.SearchResult{Results: []usecase.SearchHit{}}, nil
+}
+
+func TestSearchAccessFilterDepth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{
+		DefaultKnowledgeBase:    "default",
+		KnowledgeBases:          map[string]model.KnowledgeBase{"default": {Name: "default", KnowledgeBaseID: "KB"}},
+		RetrievalMaxResults:     25,
+		RetrievalMaxFilterDepth: 3,
+		AccessFilterEnabled:     true,
+		AccessFilterKey:         "allowed_groups",
+	}
+	h := NewBedrockAgentRuntimeHandler(cfg, fakeBedrockAgentRuntimeUsecase{}, nil, nil)
+
+	const userFilter = `,"retrieval":{"filter":{"op":"equals","key":"lang","value":"ja"}}`
+	tests := []struct {
+		name       string
+		groups     int
+		body       string
+		wantStatus int
+		wantError  string
+	}{
+		{name: "5 groups with a filter", groups: 5, body: userFilter, wantStatus: http.StatusOK},
+		{name: "6 groups without a filter", groups: 6, wantStatus: http.StatusOK},
+		{name: "6 groups with a filter", groups: 6, body: userFilter, wantStatus: http.StatusBadRequest,
+			wantError: "filter cannot be combined with the access filter for 6 groups"},
+		{name: "25 groups without a filter", groups: 25, wantStatus: http.StatusOK},
+		{name: "26 groups without a filter", groups: 26, wantStatus: http.StatusBadRequest,
+			wantError: "access filter for 26 groups needs 4 filter levels"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity := &model.Identity{Subject: "alice"}
+			for i := range tt.groups {
+				identity.Groups = append(identity.Groups, fmt.Sprintf("group-%d", i))
+			}
+			e := gin.New()
+			e.POST("/search", func(c *gin.Context) {
+				c.Request = c.Request.WithContext(model.WithIdentity(c.Request.Context(), identity))
+			}, h.Search)
+
+			req := httptest.NewRequest(http.MethodPost, "/search", strings.NewReader(`{"query":"q"`+tt.body+`}`))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantError) {
+				t.Errorf("body = %s, want %q", rec.Body, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestInvokeSettlesEstimatedTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{
+		DefaultKnowledgeBase: "default",
+		KnowledgeBases:       map[string]model.KnowledgeBase{"default": {Name: "default", KnowledgeBaseID: "KB"}},
+		CharactersPerToken:   3,
+	}
+	usage := &model.Usage{InputTokens: 40, OutputTokens: 2}
+	end := sse.NewAssistantEnd(sse.FinishCompleted)
+	end.Usage = usage
+
+	tests := []struct {
+		name    string
+		path    string
+		usecase fakeBedrockAgentRuntimeUsecase
+		want    int64
+	}{
+		{
+			name:    "sync",
+			path:    "/invocations/sync",
+			usecase: fakeBedrockAgentRuntimeUsecase{result: &usecase.InvokeResult{Text: "answer", Usage: *usage}},
+			want:    42,
+		},
+		{
+			name:    "stream",
+			path:    "/invocations",
+			usecase: fakeBedrockAgentRuntimeUsecase{events: []sse.AIEvent{sse.NewAssistantStart(), sse.NewAssistantDelta("answer"), end}},
+			want:    42,
+		},
+		{
+			// message.end が届かなかった場合は質問（8文字）と途中までの回答（6文字）から推定する
+			name:    "stream without usage",
+			path:    "/invocations",
+			usecase: fakeBedrockAgentRuntimeUsecase{events: []sse.AIEvent{sse.NewAssistantStart(), sse.NewAssistantDelta("answer")}},
+			want:    3 + 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewBedrockAgentRuntimeHandler(cfg, tt.usecase, nil, usecase.NewSessionUsecase(cfg, nil, nil))
+			rateLimit := fakeRateLimitUsecase{settled: make(chan int64, 1)}
+			e := gin.New()
+			e.Use(middleware.RateLimit(rateLimit))
+			e.POST("/invocations", h.InvokeStream)
+			e.POST("/invocations/sync", h.Invoke)
+			// SSE は CloseNotifier を必要とするため実サーバで受ける
+			srv := httptest.NewServer(e)
+			t.Cleanup(srv.Close)
+
+			res, err := http.Post(srv.URL+tt.path, "application/json", strings.NewReader(`{"query":"question"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200", res.StatusCode)
+			}
+			select {
+			case got := <-rateLimit.settled:
+				if got != tt.want {
+					t.Errorf("settled %d tokens, want %d", got, tt.want)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("usage was not settled against the quota")
+			}
+		})
+	}
+}
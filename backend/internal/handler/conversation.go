@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/usecase"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConversationHandler interface {
+	ListSessions(ctx *gin.Context)
+	ListMessages(ctx *gin.Context)
+}
+
+type conversationHandler struct {
+	conversationUsecase usecase.ConversationUsecase
+}
+
+func NewConversationHandler(conversationUsecase usecase.ConversationUsecase) ConversationHandler {
+	return &conversationHandler{
+		conversationUsecase: conversationUsecase,
+	}
+}
+
+func (h *conversationHandler) ListSessions(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	sessions, err := h.conversationUsecase.ListSessions(ctx, c.Query("user_id"))
+	if err != nil {
+		slog.ErrorContext(ctx, "list conversations failed", "error", err)
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+func (h *conversationHandler) ListMessages(c *gin.Context) {
+	sessionID, ok := bindSessionID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	messages, err := h.conversationUsecase.ListMessages(ctx, sessionID)
+	if err != nil {
+		slog.ErrorContext(ctx, "list messages failed", "error", err)
+		respondError(c, err)
+		return
+	}
+	if len(messages) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"session_id": sessionID, "messages": messages})
+}
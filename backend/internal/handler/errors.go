@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/transport/http/sse"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondError writes a client-safe JSON error classified from err.
+func respondError(c *gin.Context, err error) {
+	info := sse.ClassifyError(err)
+	c.JSON(info.HTTPStatus, gin.H{"error": info.Message, "code": info.Code, "retryable": info.Retryable})
+}
+
+// respondInvalid writes a 400 with the validation message, or the classified error for
+// authorization failures.
+func respondInvalid(c *gin.Context, err error) {
+	if errors.Is(err, model.ErrAccessDenied) {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/logging"
+	"aws-s3-knowledge-chatbot/backend/internal/usecase"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListSessions の1ページあたりの件数。各セッションの所有者を Bedrock に問い合わせるため小さく抑える
+const (
+	defaultSessionPageSize = 20
+	maxSessionPageSize     = 100
+)
+
+type SessionHandler interface {
+	CreateSession(ctx *gin.Context)
+	GetSession(ctx *gin.Context)
+	EndSession(ctx *gin.Context)
+	DeleteSession(ctx *gin.Context)
+	ListSessions(ctx *gin.Context)
+}
+
+type sessionHandler struct {
+	config         *config.Config
+	sessionUsecase usecase.SessionUsecase
+}
+
+func NewSessionHandler(
+	config *config.Config,
+	sessionUsecase usecase.SessionUsecase,
+) SessionHandler {
+	return &sessionHandler{
+		config:         config,
+		sessionUsecase: sessionUsecase,
+	}
+}
+
+func (h *sessionHandler) CreateSession(c *gin.Context) {
+	type req struct {
+		UserID        string `json:"user_id"`
+		TenantID      string `json:"tenant_id"`
+		KnowledgeBase string `json:"knowledge_base"`
+	}
+	var r req
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	kb, ok := h.config.GetKnowledgeBase(r.KnowledgeBase)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown knowledge_base: %q", r.KnowledgeBase)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	session, err := h.sessionUsecase.CreateSession(ctx, kb, model.Session{
+		UserID:   r.UserID,
+		TenantID: r.TenantID,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "create session failed", "error", err)
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, session)
+}
+
+func (h *sessionHandler) GetSession(c *gin.Context) {
+	id, ok := bindSessionID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	session, err := h.sessionUsecase.GetSession(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "get session failed", "error", err)
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+func (h *sessionHandler) EndSession(c *gin.Context) {
+	id, ok := bindSessionID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	session, err := h.sessionUsecase.EndSession(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "end session failed", "error", err)
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+func (h *sessionHandler) DeleteSession(c *gin.Context) {
+	id, ok := bindSessionID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := h.sessionUsecase.DeleteSession(ctx, id); err != nil {
+		slog.ErrorContext(ctx, "delete session failed", "error", err)
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *sessionHandler) ListSessions(c *gin.Context) {
+	type req struct {
+		UserID     string `form:"user_id"`
+		MaxResults int32  `form:"max_results"`
+		NextToken  string `form:"next_token"`
+	}
+	var r req
+	if err := c.ShouldBindQuery(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if r.MaxResults == 0 {
+		r.MaxResults = defaultSessionPageSize
+	}
+	if r.MaxResults < 1 || r.MaxResults > maxSessionPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("max_results must be between 1 and %d", maxSessionPageSize)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	page, err := h.sessionUsecase.ListSessions(ctx, model.ListSessionsOptions{
+		UserID:     r.UserID,
+		MaxResults: r.MaxResults,
+		NextToken:  r.NextToken,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "list sessions failed", "error", err)
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// bindSessionID reads the :id path parameter, writing a 400 response when it is malformed.
+func bindSessionID(c *gin.Context) (model.SessionID, bool) {
+	id := model.SessionID(c.Param("id"))
+	if id.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "session_id is required"})
+		return id, false
+	}
+	if err := id.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return id, false
+	}
+	c.Request = c.Request.WithContext(logging.WithSessionID(c.Request.Context(), id))
+	return id, true
+}
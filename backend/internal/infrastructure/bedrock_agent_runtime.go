@@ -2,19 +2,19 @@ package infrastructure
 
 import (
 	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
 	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"aws-s3-knowledge-chatbot/backend/internal/tracing"
 	"context"
 	"fmt"
 
 	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/document"
 	agtypes "github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-type BedrockAgentRuntimeRepository interface {
-	RetrieveAndGenerateStream(ctx context.Context, sessionID, inputText string) (*bedrockagentruntime.RetrieveAndGenerateStreamOutput, error)
-}
-
 type bedrockAgentRuntimeRepository struct {
 	config *config.Config
 	client *bedrockagentruntime.Client
@@ -30,38 +30,238 @@ func NewBedrockAgentRuntimeRepository(
 	}
 }
 
-func (r *bedrockAgentRuntimeRepository) RetrieveAndGenerateStream(ctx context.Context, sessionID, inputText string) (*bedrockagentruntime.RetrieveAndGenerateStreamOutput, error) {
+func (r *bedrockAgentRuntimeRepository) RetrieveAndGenerateStream(ctx context.Context, sessionID model.SessionID, inputText string, opts model.InvokeOptions) (_ *repository.RetrieveAndGenerateStreamOutput, err error) {
+	ctx, span := tracing.Start(ctx, "repository.RetrieveAndGenerateStream", r.spanAttributes(sessionID, opts.KnowledgeBase)...)
+	defer func() { tracing.End(span, err) }()
+	cfg, err := r.retrieveAndGenerateConfiguration(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
 	output, err := r.client.RetrieveAndGenerateStream(ctx, &bedrockagentruntime.RetrieveAndGenerateStreamInput{
-		SessionId: lo.Ternary(sessionID != "", lo.ToPtr(sessionID), nil),
-		Input:     &agtypes.RetrieveAndGenerateInput{Text: &inputText},
-		RetrieveAndGenerateConfiguration: &agtypes.RetrieveAndGenerateConfiguration{
-			Type: agtypes.RetrieveAndGenerateTypeKnowledgeBase,
-			KnowledgeBaseConfiguration: &agtypes.KnowledgeBaseRetrieveAndGenerateConfiguration{
-				KnowledgeBaseId: lo.ToPtr(r.config.KnowledgeBaseID),
-				ModelArn:        lo.ToPtr(r.config.BedrockModelArn),
-			},
-		},
+		SessionId:                        lo.Ternary(!sessionID.IsZero(), lo.ToPtr(sessionID.String()), nil),
+		Input:                            &agtypes.RetrieveAndGenerateInput{Text: &inputText},
+		RetrieveAndGenerateConfiguration: cfg,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to call RetrieveAndGenerate: %w", err)
 	}
-	return output, nil
+	stream := output.GetStream()
+	if stream == nil {
+		return nil, fmt.Errorf("nil stream returned")
+	}
+	return &repository.RetrieveAndGenerateStreamOutput{SessionID: output.SessionId, Stream: stream}, nil
 }
 
-func (r *bedrockAgentRuntimeRepository) RetrieveAndGenerate(ctx context.Context, sessionID, inputText string) (*bedrockagentruntime.RetrieveAndGenerateOutput, error) {
+func (r *bedrockAgentRuntimeRepository) RetrieveAndGenerate(ctx context.Context, sessionID model.SessionID, inputText string, opts model.InvokeOptions) (_ *bedrockagentruntime.RetrieveAndGenerateOutput, err error) {
+	ctx, span := tracing.Start(ctx, "repository.RetrieveAndGenerate", r.spanAttributes(sessionID, opts.KnowledgeBase)...)
+	defer func() { tracing.End(span, err) }()
+	cfg, err := r.retrieveAndGenerateConfiguration(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
 	output, err := r.client.RetrieveAndGenerate(ctx, &bedrockagentruntime.RetrieveAndGenerateInput{
-		SessionId: lo.Ternary(sessionID != "", lo.ToPtr(sessionID), nil),
-		Input:     &agtypes.RetrieveAndGenerateInput{Text: &inputText},
-		RetrieveAndGenerateConfiguration: &agtypes.RetrieveAndGenerateConfiguration{
-			Type: agtypes.RetrieveAndGenerateTypeKnowledgeBase,
-			KnowledgeBaseConfiguration: &agtypes.KnowledgeBaseRetrieveAndGenerateConfiguration{
-				KnowledgeBaseId: lo.ToPtr(r.config.KnowledgeBaseID),
-				ModelArn:        lo.ToPtr(r.config.BedrockModelArn),
-			},
-		},
+		SessionId:                        lo.Ternary(!sessionID.IsZero(), lo.ToPtr(sessionID.String()), nil),
+		Input:                            &agtypes.RetrieveAndGenerateInput{Text: &inputText},
+		RetrieveAndGenerateConfiguration: cfg,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to call RetrieveAndGenerate (non-stream): %w", err)
 	}
 	return output, nil
 }
+
+func (r *bedrockAgentRuntimeRepository) Retrieve(ctx context.Context, query string, opts model.SearchOptions) (_ *bedrockagentruntime.RetrieveOutput, err error) {
+	kb := r.knowledgeBase(opts.KnowledgeBase)
+	ctx, span := tracing.Start(ctx, "repository.Retrieve", attribute.String("knowledge_base.id", kb.KnowledgeBaseID))
+	defer func() { tracing.End(span, err) }()
+	retrieval, err := r.withAccessFilter(ctx, opts.Retrieval)
+	if err != nil {
+		return nil, err
+	}
+	output, err := r.client.Retrieve(ctx, &bedrockagentruntime.RetrieveInput{
+		KnowledgeBaseId:        lo.ToPtr(kb.KnowledgeBaseID),
+		RetrievalQuery:         &agtypes.KnowledgeBaseQuery{Text: &query},
+		RetrievalConfiguration: toRetrievalConfiguration(retrieval),
+		NextToken:              lo.Ternary(opts.NextToken != "", lo.ToPtr(opts.NextToken), nil),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Retrieve: %w", err)
+	}
+	return output, nil
+}
+
+func (r *bedrockAgentRuntimeRepository) spanAttributes(sessionID model.SessionID, kb model.KnowledgeBase) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("session.id", sessionID.String()),
+		attribute.String("knowledge_base.id", r.knowledgeBase(kb).KnowledgeBaseID),
+	}
+}
+
+// knowledgeBase returns the knowledge base selected for the request, falling back to the default.
+func (r *bedrockAgentRuntimeRepository) knowledgeBase(kb model.KnowledgeBase) model.KnowledgeBase {
+	if kb.KnowledgeBaseID != "" {
+		return kb
+	}
+	kb, _ = r.config.GetKnowledgeBase("")
+	return kb
+}
+
+// withAccessFilter restricts retrieval to documents the caller in ctx may read. The access filter is
+// ANDed with the request's own filter, so clients can narrow results but never widen them.
+func (r *bedrockAgentRuntimeRepository) withAccessFilter(ctx context.Context, o *model.RetrievalOptions) (*model.RetrievalOptions, error) {
+	if !r.config.AccessFilterEnabled {
+		return o, nil
+	}
+	groups := model.IdentityFromContext(ctx).AccessGroups(r.config.AccessFilterPublicGroups)
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("caller has no groups to filter documents by: %w", model.ErrAccessDenied)
+	}
+	filter := anyOf(lo.Map(groups, func(g string, _ int) model.MetadataFilter {
+		return model.MetadataFilter{Operator: model.FilterListContains, Key: r.config.AccessFilterKey, Value: g}
+	}))
+
+	retrieval := lo.FromPtr(o)
+	if retrieval.Filter != nil {
+		filter = model.MetadataFilter{Operator: model.FilterAndAll, Filters: []model.MetadataFilter{filter, *retrieval.Filter}}
+	}
+	if depth := filter.Depth(); depth > r.config.RetrievalMaxFilterDepth {
+		// 通常はハンドラの検証で弾かれる
+		return nil, fmt.Errorf("access filter for %d groups needs %d filter levels, exceeding the limit of %d: %w",
+			len(groups), depth, r.config.RetrievalMaxFilterDepth, model.ErrInvalidArgument)
+	}
+	retrieval.Filter = &filter
+	return &retrieval, nil
+}
+
+// anyOf ORs filters together. Bedrock accepts at most model.MaxFilterGroupSize members per orAll,
+// so larger sets are split into nested orAll groups.
+func anyOf(filters []model.MetadataFilter) model.MetadataFilter {
+	for len(filters) > model.MaxFilterGroupSize {
+		filters = lo.Map(lo.Chunk(filters, model.MaxFilterGroupSize), func(chunk []model.MetadataFilter, _ int) model.MetadataFilter {
+			return anyOf(chunk)
+		})
+	}
+	// orAll / andAll は2件以上の条件が必要
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return model.MetadataFilter{Operator: model.FilterOrAll, Filters: filters}
+}
+
+func (r *bedrockAgentRuntimeRepository) retrieveAndGenerateConfiguration(ctx context.Context, opts model.InvokeOptions) (*agtypes.RetrieveAndGenerateConfiguration, error) {
+	kb := r.knowledgeBase(opts.KnowledgeBase)
+	retrieval, err := r.withAccessFilter(ctx, opts.Retrieval)
+	if err != nil {
+		return nil, err
+	}
+	return &agtypes.RetrieveAndGenerateConfiguration{
+		Type: agtypes.RetrieveAndGenerateTypeKnowledgeBase,
+		KnowledgeBaseConfiguration: &agtypes.KnowledgeBaseRetrieveAndGenerateConfiguration{
+			KnowledgeBaseId:            lo.ToPtr(kb.KnowledgeBaseID),
+			ModelArn:                   lo.ToPtr(lo.CoalesceOrEmpty(opts.ModelArn, kb.ModelArn)),
+			RetrievalConfiguration:     toRetrievalConfiguration(retrieval),
+			GenerationConfiguration:    toGenerationConfiguration(opts.PromptTemplate, opts.Inference),
+			OrchestrationConfiguration: toOrchestrationConfiguration(opts.Orchestration),
+		},
+	}, nil
+}
+
+func toOrchestrationConfiguration(o *model.OrchestrationOptions) *agtypes.OrchestrationConfiguration {
+	if o == nil {
+		return nil
+	}
+	oc := &agtypes.OrchestrationConfiguration{
+		InferenceConfig: toInferenceConfig(o.Inference),
+	}
+	if o.QueryDecomposition {
+		oc.QueryTransformationConfiguration = &agtypes.QueryTransformationConfiguration{
+			Type: agtypes.QueryTransformationTypeQueryDecomposition,
+		}
+	}
+	if o.PromptTemplate != nil {
+		oc.PromptTemplate = &agtypes.PromptTemplate{TextPromptTemplate: lo.ToPtr(o.PromptTemplate.Text)}
+	}
+	return oc
+}
+
+func toGenerationConfiguration(t *model.PromptTemplate, o *model.InferenceOptions) *agtypes.GenerationConfiguration {
+	if t == nil && o == nil {
+		return nil
+	}
+	gc := &agtypes.GenerationConfiguration{}
+	if t != nil {
+		gc.PromptTemplate = &agtypes.PromptTemplate{TextPromptTemplate: lo.ToPtr(t.Text)}
+	}
+	gc.InferenceConfig = toInferenceConfig(o)
+	return gc
+}
+
+func toInferenceConfig(o *model.InferenceOptions) *agtypes.InferenceConfig {
+	if o == nil {
+		return nil
+	}
+	return &agtypes.InferenceConfig{
+		TextInferenceConfig: &agtypes.TextInferenceConfig{
+			Temperature:   o.Temperature,
+			TopP:          o.TopP,
+			MaxTokens:     o.MaxTokens,
+			StopSequences: o.StopSequences,
+		},
+	}
+}
+
+func toRetrievalConfiguration(o *model.RetrievalOptions) *agtypes.KnowledgeBaseRetrievalConfiguration {
+	if o == nil {
+		return nil
+	}
+	return &agtypes.KnowledgeBaseRetrievalConfiguration{
+		VectorSearchConfiguration: &agtypes.KnowledgeBaseVectorSearchConfiguration{
+			NumberOfResults:    lo.Ternary(o.NumberOfResults > 0, lo.ToPtr(o.NumberOfResults), nil),
+			OverrideSearchType: agtypes.SearchType(o.SearchType),
+			Filter:             toRetrievalFilter(o.Filter),
+		},
+	}
+}
+
+func toRetrievalFilter(f *model.MetadataFilter) agtypes.RetrievalFilter {
+	if f == nil {
+		return nil
+	}
+	attr := agtypes.FilterAttribute{Key: lo.ToPtr(f.Key), Value: document.NewLazyDocument(f.Value)}
+	switch f.Operator {
+	case model.FilterAndAll:
+		return &agtypes.RetrievalFilterMemberAndAll{Value: toRetrievalFilters(f.Filters)}
+	case model.FilterOrAll:
+		return &agtypes.RetrievalFilterMemberOrAll{Value: toRetrievalFilters(f.Filters)}
+	case model.FilterEquals:
+		return &agtypes.RetrievalFilterMemberEquals{Value: attr}
+	case model.FilterNotEquals:
+		return &agtypes.RetrievalFilterMemberNotEquals{Value: attr}
+	case model.FilterGreaterThan:
+		return &agtypes.RetrievalFilterMemberGreaterThan{Value: attr}
+	case model.FilterGreaterThanOrEquals:
+		return &agtypes.RetrievalFilterMemberGreaterThanOrEquals{Value: attr}
+	case model.FilterLessThan:
+		return &agtypes.RetrievalFilterMemberLessThan{Value: attr}
+	case model.FilterLessThanOrEquals:
+		return &agtypes.RetrievalFilterMemberLessThanOrEquals{Value: attr}
+	case model.FilterIn:
+		return &agtypes.RetrievalFilterMemberIn{Value: attr}
+	case model.FilterNotIn:
+		return &agtypes.RetrievalFilterMemberNotIn{Value: attr}
+	case model.FilterStartsWith:
+		return &agtypes.RetrievalFilterMemberStartsWith{Value: attr}
+	case model.FilterListContains:
+		return &agtypes.RetrievalFilterMemberListContains{Value: attr}
+	case model.FilterStringContains:
+		return &agtypes.RetrievalFilterMemberStringContains{Value: attr}
+	default:
+		return nil
+	}
+}
+
+func toRetrievalFilters(filters []model.MetadataFilter) []agtypes.RetrievalFilter {
+	return lo.Map(filters, func(f model.MetadataFilter, _ int) agtypes.RetrievalFilter {
+		return toRetrievalFilter(&f)
+	})
+}
@@ -0,0 +1,243 @@
+package infrastructure
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// テーブル設計:
+//
+//	pk=session_id, sk="MSG#<message id>" … メッセージ（IDはULIDのため sk 順が時系列順）
+//	pk=session_id, sk="META"             … セッションの集計（user_id, created_at, updated_at, message_count）
+//
+// user_id をパーティションキーに持つ GSI があればユーザー毎の一覧に使い、無ければ Scan する
+const (
+	conversationMessagePrefix = "MSG#"
+	conversationMetaKey       = "META"
+)
+
+type conversationDynamoDBRepository struct {
+	client    *dynamodb.Client
+	table     string
+	userIndex string
+}
+
+type conversationMessageItem struct {
+	PK model.SessionID `json:"pk"`
+	SK string          `json:"sk"`
+	model.Message
+}
+
+type conversationMetaItem struct {
+	PK           model.SessionID `json:"pk"`
+	SK           string          `json:"sk"`
+	UserID       string          `json:"user_id,omitempty"`
+	MessageCount int             `json:"message_count"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+func NewConversationDynamoDBRepository(config *config.Config, client *dynamodb.Client) repository.ConversationRepository {
+	return &conversationDynamoDBRepository{
+		client:    client,
+		table:     config.ConversationTable,
+		userIndex: config.ConversationUserIndex,
+	}
+}
+
+func (r *conversationDynamoDBRepository) AppendMessages(ctx context.Context, messages ...model.Message) error {
+	for _, m := range messages {
+		item, err := marshalDynamoDBItem(conversationMessageItem{
+			PK:      m.SessionID,
+			SK:      conversationMessagePrefix + m.ID,
+			Message: m,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(r.table),
+			Item:      item,
+		}); err != nil {
+			return fmt.Errorf("put message: %w", err)
+		}
+		if err := r.touchSession(ctx, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// touchSession updates the META item of the message's session.
+func (r *conversationDynamoDBRepository) touchSession(ctx context.Context, m model.Message) error {
+	createdAt, err := attributevalue.Marshal(m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("marshal created_at: %w", err)
+	}
+	expr := "SET updated_at = :t, created_at = if_not_exists(created_at, :t) ADD message_count :one"
+	values := map[string]types.AttributeValue{
+		":t":   createdAt,
+		":one": &types.AttributeValueMemberN{Value: "1"},
+	}
+	// GSI のキー属性に空文字は入れられないため、ユーザーが分かる場合のみ設定する
+	if m.UserID != "" {
+		expr = "SET updated_at = :t, created_at = if_not_exists(created_at, :t), user_id = if_not_exists(user_id, :u) ADD message_count :one"
+		values[":u"] = &types.AttributeValueMemberS{Value: m.UserID}
+	}
+	if _, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(r.table),
+		Key:                       conversationKey(m.SessionID, conversationMetaKey),
+		UpdateExpression:          aws.String(expr),
+		ExpressionAttributeValues: values,
+	}); err != nil {
+		return fmt.Errorf("update session: %w", err)
+	}
+	return nil
+}
+
+func (r *conversationDynamoDBRepository) ListMessages(ctx context.Context, sessionID model.SessionID) ([]model.Message, error) {
+	p := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.table),
+		KeyConditionExpression: aws.String("pk = :pk AND begins_with(sk, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: sessionID.String()},
+			":prefix": &types.AttributeValueMemberS{Value: conversationMessagePrefix},
+		},
+	})
+	var messages []model.Message
+	for p.HasMorePages() {
+		out, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("query messages: %w", err)
+		}
+		for _, item := range out.Items {
+			var m conversationMessageItem
+			if err := unmarshalDynamoDBItem(item, &m); err != nil {
+				return nil, err
+			}
+			messages = append(messages, m.Message)
+		}
+	}
+	return messages, nil
+}
+
+func (r *conversationDynamoDBRepository) DeleteMessages(ctx context.Context, sessionID model.SessionID) error {
+	// META も含めセッションの全アイテムを削除する
+	p := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.table),
+		KeyConditionExpression: aws.String("pk = :pk"),
+		ProjectionExpression:   aws.String("sk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: sessionID.String()},
+		},
+	})
+	for p.HasMorePages() {
+		out, err := p.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("query session items: %w", err)
+		}
+		for _, item := range out.Items {
+			sk, ok := item["sk"].(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+			if _, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+				TableName: aws.String(r.table),
+				Key:       conversationKey(sessionID, sk.Value),
+			}); err != nil {
+				return fmt.Errorf("delete session item: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *conversationDynamoDBRepository) ListSessions(ctx context.Context, userID string) ([]model.SessionSummary, error) {
+	var items []map[string]types.AttributeValue
+	if userID != "" && r.userIndex != "" {
+		p := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+			TableName:              aws.String(r.table),
+			IndexName:              aws.String(r.userIndex),
+			KeyConditionExpression: aws.String("user_id = :u"),
+			FilterExpression:       aws.String("sk = :meta"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":u":    &types.AttributeValueMemberS{Value: userID},
+				":meta": &types.AttributeValueMemberS{Value: conversationMetaKey},
+			},
+		})
+		for p.HasMorePages() {
+			out, err := p.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("query sessions: %w", err)
+			}
+			items = append(items, out.Items...)
+		}
+	} else {
+		p := dynamodb.NewScanPaginator(r.client, &dynamodb.ScanInput{
+			TableName:        aws.String(r.table),
+			FilterExpression: aws.String("sk = :meta"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":meta": &types.AttributeValueMemberS{Value: conversationMetaKey},
+			},
+		})
+		for p.HasMorePages() {
+			out, err := p.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("scan sessions: %w", err)
+			}
+			items = append(items, out.Items...)
+		}
+	}
+
+	sessions := make([]model.SessionSummary, 0, len(items))
+	for _, item := range items {
+		var meta conversationMetaItem
+		if err := unmarshalDynamoDBItem(item, &meta); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, model.SessionSummary{
+			SessionID:    meta.PK,
+			UserID:       meta.UserID,
+			MessageCount: meta.MessageCount,
+			CreatedAt:    meta.CreatedAt,
+			UpdatedAt:    meta.UpdatedAt,
+		})
+	}
+	return sortSessions(filterSessions(sessions, userID)), nil
+}
+
+func conversationKey(sessionID model.SessionID, sk string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: sessionID.String()},
+		"sk": &types.AttributeValueMemberS{Value: sk},
+	}
+}
+
+// ドメインモデルの json タグをそのまま属性名として使う
+func marshalDynamoDBItem(v any) (map[string]types.AttributeValue, error) {
+	item, err := attributevalue.MarshalMapWithOptions(v, func(o *attributevalue.EncoderOptions) {
+		o.TagKey = "json"
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal item: %w", err)
+	}
+	return item, nil
+}
+
+func unmarshalDynamoDBItem(item map[string]types.AttributeValue, v any) error {
+	if err := attributevalue.UnmarshalMapWithOptions(item, v, func(o *attributevalue.DecoderOptions) {
+		o.TagKey = "json"
+	}); err != nil {
+		return fmt.Errorf("unmarshal item: %w", err)
+	}
+	return nil
+}
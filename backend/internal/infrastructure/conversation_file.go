@@ -0,0 +1,160 @@
+package infrastructure
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const conversationFileExt = ".jsonl"
+
+type conversationFileRepository struct {
+	mu  sync.RWMutex
+	dir string
+}
+
+// NewConversationFileRepository stores each session as a JSON Lines file under dir.
+func NewConversationFileRepository(dir string) (repository.ConversationRepository, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create conversation dir: %w", err)
+	}
+	return &conversationFileRepository{dir: dir}, nil
+}
+
+func (r *conversationFileRepository) AppendMessages(_ context.Context, messages ...model.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range messages {
+		path, err := r.path(m.SessionID)
+		if err != nil {
+			return err
+		}
+		if err := appendJSONLine(path, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *conversationFileRepository) ListMessages(_ context.Context, sessionID model.SessionID) ([]model.Message, error) {
+	path, err := r.path(sessionID)
+	if err != nil {
+		// ファイル名として不正なIDのセッションは存在しない
+		return nil, nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return readMessages(path)
+}
+
+func (r *conversationFileRepository) DeleteMessages(_ context.Context, sessionID model.SessionID) error {
+	path, err := r.path(sessionID)
+	if err != nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove conversation file: %w", err)
+	}
+	return nil
+}
+
+func (r *conversationFileRepository) ListSessions(_ context.Context, userID string) ([]model.SessionSummary, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read conversation dir: %w", err)
+	}
+	var sessions []model.SessionSummary
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), conversationFileExt) {
+			continue
+		}
+		messages, err := readMessages(filepath.Join(r.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if len(messages) > 0 {
+			sessions = append(sessions, summarizeSession(messages))
+		}
+	}
+	return sortSessions(filterSessions(sessions, userID)), nil
+}
+
+func (r *conversationFileRepository) path(sessionID model.SessionID) (string, error) {
+	if sessionID.IsZero() {
+		return "", fmt.Errorf("session id is required")
+	}
+	if err := sessionID.Validate(); err != nil {
+		return "", err
+	}
+	return filepath.Join(r.dir, sessionFileName(sessionID)), nil
+}
+
+// sessionFileName percent-encodes every byte other than [A-Za-z0-9_-] (e.g. "." and ":"),
+// so that no session ID can traverse directories. IDs made of those characters only keep
+// their name unchanged.
+func sessionFileName(sessionID model.SessionID) string {
+	var b strings.Builder
+	for _, c := range []byte(sessionID) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '_', c == '-':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String() + conversationFileExt
+}
+
+func appendJSONLine(path string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal message: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open conversation file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("write conversation file: %w", err)
+	}
+	return nil
+}
+
+func readMessages(path string) ([]model.Message, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open conversation file: %w", err)
+	}
+	defer f.Close()
+
+	var messages []model.Message
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		var m model.Message
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			return nil, fmt.Errorf("parse conversation file %s: %w", path, err)
+		}
+		messages = append(messages, m)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read conversation file: %w", err)
+	}
+	return messages, nil
+}
@@ -0,0 +1,86 @@
+package infrastructure
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/samber/lo"
+)
+
+type conversationMemoryRepository struct {
+	mu       sync.RWMutex
+	messages map[model.SessionID][]model.Message
+}
+
+// NewConversationMemoryRepository keeps conversations in process memory (lost on restart).
+func NewConversationMemoryRepository() repository.ConversationRepository {
+	return &conversationMemoryRepository{
+		messages: map[model.SessionID][]model.Message{},
+	}
+}
+
+func (r *conversationMemoryRepository) AppendMessages(_ context.Context, messages ...model.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range messages {
+		r.messages[m.SessionID] = append(r.messages[m.SessionID], m)
+	}
+	return nil
+}
+
+func (r *conversationMemoryRepository) ListMessages(_ context.Context, sessionID model.SessionID) ([]model.Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.messages[sessionID]), nil
+}
+
+func (r *conversationMemoryRepository) DeleteMessages(_ context.Context, sessionID model.SessionID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.messages, sessionID)
+	return nil
+}
+
+func (r *conversationMemoryRepository) ListSessions(_ context.Context, userID string) ([]model.SessionSummary, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sessions := lo.MapToSlice(r.messages, func(_ model.SessionID, messages []model.Message) model.SessionSummary {
+		return summarizeSession(messages)
+	})
+	return sortSessions(filterSessions(sessions, userID)), nil
+}
+
+// summarizeSession builds a summary from a session's messages in chronological order.
+func summarizeSession(messages []model.Message) model.SessionSummary {
+	if len(messages) == 0 {
+		return model.SessionSummary{}
+	}
+	first, last := messages[0], messages[len(messages)-1]
+	return model.SessionSummary{
+		SessionID:    first.SessionID,
+		UserID:       first.UserID,
+		MessageCount: len(messages),
+		CreatedAt:    first.CreatedAt,
+		UpdatedAt:    last.CreatedAt,
+	}
+}
+
+func filterSessions(sessions []model.SessionSummary, userID string) []model.SessionSummary {
+	if userID == "" {
+		return sessions
+	}
+	return lo.Filter(sessions, func(s model.SessionSummary, _ int) bool {
+		return s.UserID == userID
+	})
+}
+
+// sortSessions orders sessions by last activity, newest first.
+func sortSessions(sessions []model.SessionSummary) []model.SessionSummary {
+	slices.SortFunc(sessions, func(a, b model.SessionSummary) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	return sessions
+}
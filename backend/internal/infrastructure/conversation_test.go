@@ -0,0 +1,156 @@
+package infrastructure
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/client"
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestConversationMemoryRepository(t *testing.T) {
+	testConversationRepository(t, NewConversationMemoryRepository())
+}
+
+func TestConversationFileRepository(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewConversationFileRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testConversationRepository(t, r)
+
+	// ディレクトリ外を指すIDはファイル名に使わない
+	if err := r.AppendMessages(context.Background(), model.Message{ID: "x", SessionID: "../escape"}); err == nil {
+		t.Error("AppendMessages accepted a session id with a path separator")
+	}
+	if _, err := os.Stat(dir + "/../escape.jsonl"); err == nil {
+		t.Error("a file was written outside the conversation dir")
+	}
+}
+
+// TestConversationDynamoDBRepository runs against DynamoDB Local (or any endpoint) when
+// DYNAMODB_ENDPOINT is set, e.g. `docker compose --profile dynamodb up dynamodb-local`.
+func TestConversationDynamoDBRepository(t *testing.T) {
+	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_ENDPOINT is not set")
+	}
+	// DynamoDB Local は認証情報の中身を検証しない
+	t.Setenv("AWS_ACCESS_KEY_ID", "local")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "local")
+	cfg := &config.Config{
+		AwsRegion:             "ap-northeast-1",
+		DynamoDBEndpoint:      endpoint,
+		ConversationTable:     fmt.Sprintf("conversations-test-%d", time.Now().UnixNano()),
+		ConversationUserIndex: "user_id-index",
+	}
+	c, err := client.NewDynamoDBClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := c.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(cfg.ConversationTable),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("pk"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("sk"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("user_id"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("pk"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("sk"), KeyType: types.KeyTypeRange},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName:  aws.String(cfg.ConversationUserIndex),
+			KeySchema:  []types.KeySchemaElement{{AttributeName: aws.String("user_id"), KeyType: types.KeyTypeHash}},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+	}); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = c.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: aws.String(cfg.ConversationTable)})
+	})
+	testConversationRepository(t, NewConversationDynamoDBRepository(cfg, c))
+}
+
+// testConversationRepository checks the behaviour shared by every conversation store.
+func testConversationRepository(t *testing.T, r repository.ConversationRepository) {
+	t.Helper()
+	ctx := context.Background()
+	base := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	// "." と ":" を含むIDも model.SessionID としては有効
+	sessionA, sessionB := model.SessionID("session-a"), model.SessionID("session.b:1")
+
+	if err := r.AppendMessages(ctx,
+		model.Message{ID: "01A", SessionID: sessionA, UserID: "alice", Role: model.MessageRoleUser, Text: "question", CreatedAt: base},
+		model.Message{ID: "01B", SessionID: sessionA, UserID: "alice", Role: model.MessageRoleAssistant, Text: "answer", CreatedAt: base.Add(time.Second),
+			Citations: []model.Citation{{Number: 1, Source: "s3://bucket/doc.pdf"}}},
+	); err != nil {
+		t.Fatalf("AppendMessages: %v", err)
+	}
+	if err := r.AppendMessages(ctx,
+		model.Message{ID: "01C", SessionID: sessionB, UserID: "bob", Role: model.MessageRoleUser, Text: "other", CreatedAt: base.Add(time.Minute)},
+	); err != nil {
+		t.Fatalf("AppendMessages: %v", err)
+	}
+
+	messages, err := r.ListMessages(ctx, sessionA)
+	if err != nil {
+		t.Fatalf("ListMessages: %v", err)
+	}
+	if len(messages) != 2 || messages[0].ID != "01A" || messages[1].ID != "01B" {
+		t.Fatalf("ListMessages = %+v, want 01A, 01B in order", messages)
+	}
+	if got := messages[1]; got.Text != "answer" || got.UserID != "alice" || !got.CreatedAt.Equal(base.Add(time.Second)) || len(got.Citations) != 1 {
+		t.Errorf("ListMessages[1] = %+v", got)
+	}
+	if messages, err := r.ListMessages(ctx, sessionB); err != nil || len(messages) != 1 {
+		t.Errorf("ListMessages(%s) = %+v, %v; want 1 message", sessionB, messages, err)
+	}
+	if messages, err := r.ListMessages(ctx, "unknown"); err != nil || len(messages) != 0 {
+		t.Errorf("ListMessages(unknown) = %+v, %v; want none", messages, err)
+	}
+
+	sessions, err := r.ListSessions(ctx, "")
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].SessionID != sessionB || sessions[1].SessionID != sessionA {
+		t.Fatalf("ListSessions = %+v, want %s then %s", sessions, sessionB, sessionA)
+	}
+	sessions, err = r.ListSessions(ctx, "alice")
+	if err != nil {
+		t.Fatalf("ListSessions(alice): %v", err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("ListSessions(alice) = %+v, want 1 session", sessions)
+	}
+	if got := sessions[0]; got.SessionID != sessionA || got.UserID != "alice" || got.MessageCount != 2 ||
+		!got.CreatedAt.Equal(base) || !got.UpdatedAt.Equal(base.Add(time.Second)) {
+		t.Errorf("ListSessions(alice)[0] = %+v", got)
+	}
+
+	if err := r.DeleteMessages(ctx, sessionA); err != nil {
+		t.Fatalf("DeleteMessages: %v", err)
+	}
+	if messages, err := r.ListMessages(ctx, sessionA); err != nil || len(messages) != 0 {
+		t.Errorf("ListMessages after delete = %+v, %v; want none", messages, err)
+	}
+	if sessions, err := r.ListSessions(ctx, ""); err != nil || len(sessions) != 1 || sessions[0].SessionID != sessionB {
+		t.Errorf("ListSessions after delete = %+v, %v; want only %s", sessions, err, sessionB)
+	}
+	if err := r.DeleteMessages(ctx, "unknown"); err != nil {
+		t.Errorf("DeleteMessages(unknown) = %v, want nil", err)
+	}
+}
@@ -0,0 +1,42 @@
+package infrastructure
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/samber/lo"
+)
+
+type documentRepository struct {
+	config  *config.Config
+	presign *s3.PresignClient
+}
+
+func NewDocumentRepository(
+	config *config.Config,
+	client *s3.Client,
+) repository.DocumentRepository {
+	return &documentRepository{
+		config:  config,
+		presign: s3.NewPresignClient(client),
+	}
+}
+
+func (r *documentRepository) PresignURL(ctx context.Context, uri string) (string, error) {
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(uri, "s3://"), "/")
+	if !strings.HasPrefix(uri, "s3://") || !ok || bucket == "" || key == "" {
+		return "", fmt.Errorf("invalid s3 uri: %q", uri)
+	}
+	req, err := r.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: lo.ToPtr(bucket),
+		Key:    lo.ToPtr(key),
+	}, s3.WithPresignExpires(r.config.CitationPresignTTL))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign GetObject: %w", err)
+	}
+	return req.URL, nil
+}
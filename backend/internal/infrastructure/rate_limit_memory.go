@@ -0,0 +1,128 @@
+package infrastructure
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// 満タンに戻ったバケットや期限切れのカウンタを掃除する間隔
+const rateLimitSweepInterval = time.Minute
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	limit   model.RateLimit
+}
+
+type expiringCounter struct {
+	value   int64
+	expires time.Time
+}
+
+type rateLimitMemoryRepository struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	counters  map[string]*expiringCounter
+	lastSweep time.Time
+}
+
+// NewRateLimitMemoryRepository keeps rate limiting state in process memory (per instance).
+func NewRateLimitMemoryRepository() repository.RateLimitRepository {
+	return &rateLimitMemoryRepository{
+		buckets:   map[string]*tokenBucket{},
+		counters:  map[string]*expiringCounter{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (r *rateLimitMemoryRepository) Take(_ context.Context, key string, limit model.RateLimit) (bool, time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.sweep(now)
+
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(limit.Burst), updated: now}
+		r.buckets[key] = b
+	}
+	b.limit = limit
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+	b.updated = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second)), nil
+}
+
+func (r *rateLimitMemoryRepository) Acquire(_ context.Context, key string, max int, ttl time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.counter(key, time.Now())
+	if c.value >= int64(max) {
+		return false, nil
+	}
+	c.value++
+	c.expires = time.Now().Add(ttl)
+	return true, nil
+}
+
+func (r *rateLimitMemoryRepository) Release(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.counters[key]; ok && c.value > 0 {
+		c.value--
+	}
+	return nil
+}
+
+func (r *rateLimitMemoryRepository) Usage(_ context.Context, key string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counter(key, time.Now()).value, nil
+}
+
+func (r *rateLimitMemoryRepository) AddUsage(_ context.Context, key string, amount int64, ttl time.Duration) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	c := r.counter(key, now)
+	if c.value == 0 {
+		c.expires = now.Add(ttl)
+	}
+	c.value += amount
+	return c.value, nil
+}
+
+// counter returns the live counter for key, resetting it once expired. Callers must hold mu.
+func (r *rateLimitMemoryRepository) counter(key string, now time.Time) *expiringCounter {
+	c, ok := r.counters[key]
+	if !ok || (!c.expires.IsZero() && now.After(c.expires)) {
+		c = &expiringCounter{}
+		r.counters[key] = c
+	}
+	return c
+}
+
+// sweep drops state that no longer affects any decision. Callers must hold mu.
+func (r *rateLimitMemoryRepository) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	r.lastSweep = now
+	for k, b := range r.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*b.limit.Rate >= float64(b.limit.Burst) {
+			delete(r.buckets, k)
+		}
+	}
+	for k, c := range r.counters {
+		if c.value == 0 || (!c.expires.IsZero() && now.After(c.expires)) {
+			delete(r.counters, k)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package infrastructure
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// トークンバケット。時刻は Redis の TIME を使い、インスタンス間の時計ずれの影響を受けない
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(b[1]) or burst
+local ts = tonumber(b[2]) or now
+tokens = math.min(burst, tokens + (now - ts) / 1000 * rate)
+local allowed, wait = 0, 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, wait}
+`)
+
+var acquireScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+if n > tonumber(ARGV[1]) then
+  redis.call('DECR', KEYS[1])
+  return 0
+end
+return 1
+`)
+
+var releaseScript = redis.NewScript(`
+local n = redis.call('DECR', KEYS[1])
+if n < 0 then
+  redis.call('SET', KEYS[1], 0, 'KEEPTTL')
+end
+return n
+`)
+
+// 初回加算時のみ有効期限を設定する（EXPIRE NX は Redis 7 以降のため使わない）
+var addUsageScript = redis.NewScript(`
+local n = redis.call('INCRBY', KEYS[1], ARGV[1])
+if redis.call('PTTL', KEYS[1]) < 0 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return n
+`)
+
+type rateLimitRedisRepository struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRateLimitRedisRepository shares rate limiting state through Redis (or a compatible store such as Valkey).
+func NewRateLimitRedisRepository(client redis.UniversalClient, prefix string) repository.RateLimitRepository {
+	return &rateLimitRedisRepository{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (r *rateLimitRedisRepository) Take(ctx context.Context, key string, limit model.RateLimit) (bool, time.Duration, error) {
+	res, err := takeTokenScript.Run(ctx, r.client, []string{r.prefix + key}, limit.Rate, limit.Burst).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("take token: %w", err)
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
+
+func (r *rateLimitRedisRepository) Acquire(ctx context.Context, key string, max int, ttl time.Duration) (bool, error) {
+	ok, err := acquireScript.Run(ctx, r.client, []string{r.prefix + key}, max, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("acquire slot: %w", err)
+	}
+	return ok == 1, nil
+}
+
+func (r *rateLimitRedisRepository) Release(ctx context.Context, key string) error {
+	if err := releaseScript.Run(ctx, r.client, []string{r.prefix + key}).Err(); err != nil {
+		return fmt.Errorf("release slot: %w", err)
+	}
+	return nil
+}
+
+func (r *rateLimitRedisRepository) Usage(ctx context.Context, key string) (int64, error) {
+	n, err := r.client.Get(ctx, r.prefix+key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get usage: %w", err)
+	}
+	return n, nil
+}
+
+func (r *rateLimitRedisRepository) AddUsage(ctx context.Context, key string, amount int64, ttl time.Duration) (int64, error) {
+	n, err := addUsageScript.Run(ctx, r.client, []string{r.prefix + key}, amount, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("add usage: %w", err)
+	}
+	return n, nil
+}
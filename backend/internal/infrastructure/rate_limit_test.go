@@ -0,0 +1,125 @@
+package infrastructure
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/client"
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRateLimitMemoryRepository(t *testing.T) {
+	testRateLimitRepository(t, NewRateLimitMemoryRepository())
+}
+
+// TestRateLimitRedisRepository runs against Redis when REDIS_ADDR is set,
+// e.g. `docker compose --profile redis up redis` and REDIS_ADDR=localhost:6379.
+func TestRateLimitRedisRepository(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	c, err := client.NewRedisClient(&config.Config{RedisAddr: addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := fmt.Sprintf("chatbot-test-%d:", time.Now().UnixNano())
+	t.Cleanup(func() {
+		ctx := context.Background()
+		keys, _ := c.Keys(ctx, prefix+"*").Result()
+		if len(keys) > 0 {
+			_ = c.Del(ctx, keys...).Err()
+		}
+		_ = c.Close()
+	})
+	testRateLimitRepository(t, NewRateLimitRedisRepository(c, prefix))
+}
+
+// testRateLimitRepository checks the behaviour shared by every rate limit store.
+func testRateLimitRepository(t *testing.T, r repository.RateLimitRepository) {
+	t.Helper()
+	ctx := context.Background()
+
+	t.Run("token bucket", func(t *testing.T) {
+		limit := model.RateLimit{Rate: 0.5, Burst: 2}
+		for i := range limit.Burst {
+			if ok, _, err := r.Take(ctx, "rate:user:alice", limit); err != nil || !ok {
+				t.Fatalf("Take #%d = %v, %v; want allowed", i+1, ok, err)
+			}
+		}
+		ok, wait, err := r.Take(ctx, "rate:user:alice", limit)
+		if err != nil || ok {
+			t.Fatalf("Take over burst = %v, %v; want denied", ok, err)
+		}
+		// 0.5 rps なので次のトークンまで最大2秒
+		if wait <= 0 || wait > 2*time.Second {
+			t.Errorf("wait = %v, want (0, 2s]", wait)
+		}
+		// バケットはキー毎に独立している
+		if ok, _, err := r.Take(ctx, "rate:user:bob", limit); err != nil || !ok {
+			t.Errorf("Take(bob) = %v, %v; want allowed", ok, err)
+		}
+	})
+
+	t.Run("concurrency slots", func(t *testing.T) {
+		for i := range 2 {
+			if ok, err := r.Acquire(ctx, "streams:user:alice", 2, time.Minute); err != nil || !ok {
+				t.Fatalf("Acquire #%d = %v, %v; want acquired", i+1, ok, err)
+			}
+		}
+		if ok, err := r.Acquire(ctx, "streams:user:alice", 2, time.Minute); err != nil || ok {
+			t.Fatalf("Acquire over max = %v, %v; want refused", ok, err)
+		}
+		if err := r.Release(ctx, "streams:user:alice"); err != nil {
+			t.Fatalf("Release: %v", err)
+		}
+		if ok, err := r.Acquire(ctx, "streams:user:alice", 2, time.Minute); err != nil || !ok {
+			t.Errorf("Acquire after release = %v, %v; want acquired", ok, err)
+		}
+		// 取得していない枠の解放で負の値にならない
+		for range 3 {
+			if err := r.Release(ctx, "streams:user:bob"); err != nil {
+				t.Fatalf("Release: %v", err)
+			}
+		}
+		if ok, err := r.Acquire(ctx, "streams:user:bob", 1, time.Minute); err != nil || !ok {
+			t.Fatalf("Acquire(bob) = %v, %v; want acquired", ok, err)
+		}
+		if ok, err := r.Acquire(ctx, "streams:user:bob", 1, time.Minute); err != nil || ok {
+			t.Errorf("Acquire(bob) over max = %v, %v; want refused", ok, err)
+		}
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		if used, err := r.Usage(ctx, "quota:user:alice"); err != nil || used != 0 {
+			t.Fatalf("Usage before add = %d, %v; want 0", used, err)
+		}
+		if total, err := r.AddUsage(ctx, "quota:user:alice", 120, time.Minute); err != nil || total != 120 {
+			t.Fatalf("AddUsage = %d, %v; want 120", total, err)
+		}
+		if total, err := r.AddUsage(ctx, "quota:user:alice", 30, time.Minute); err != nil || total != 150 {
+			t.Fatalf("AddUsage = %d, %v; want 150", total, err)
+		}
+		if used, err := r.Usage(ctx, "quota:user:alice"); err != nil || used != 150 {
+			t.Errorf("Usage = %d, %v; want 150", used, err)
+		}
+	})
+
+	t.Run("usage expires", func(t *testing.T) {
+		if _, err := r.AddUsage(ctx, "quota:user:carol", 10, 100*time.Millisecond); err != nil {
+			t.Fatalf("AddUsage: %v", err)
+		}
+		// 2回目以降の加算では有効期限を延長しない
+		if _, err := r.AddUsage(ctx, "quota:user:carol", 10, time.Hour); err != nil {
+			t.Fatalf("AddUsage: %v", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+		if used, err := r.Usage(ctx, "quota:user:carol"); err != nil || used != 0 {
+			t.Errorf("Usage after expiry = %d, %v; want 0", used, err)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package infrastructure
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	agtypes "github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
+)
+
+// ListSessions で所有者を解決する際の GetSession の同時実行数と、1回の一覧で走査するセッション数の上限
+const (
+	sessionMetadataConcurrency = 10
+	maxScannedSessions         = 500
+)
+
+type sessionRepository struct {
+	client *bedrockagentruntime.Client
+}
+
+func NewSessionRepository(client *bedrockagentruntime.Client) repository.SessionRepository {
+	return &sessionRepository{
+		client: client,
+	}
+}
+
+func (r *sessionRepository) CreateSession(ctx context.Context, session model.Session) (*model.Session, error) {
+	output, err := r.client.CreateSession(ctx, &bedrockagentruntime.CreateSessionInput{
+		SessionMetadata: session.Metadata(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to call CreateSession: %w", err)
+	}
+	session.ID = model.SessionID(lo.FromPtr(output.SessionId))
+	session.Arn = lo.FromPtr(output.SessionArn)
+	session.Status = model.SessionStatus(output.SessionStatus)
+	session.CreatedAt = lo.FromPtr(output.CreatedAt)
+	session.UpdatedAt = session.CreatedAt
+	return &session, nil
+}
+
+func (r *sessionRepository) GetSession(ctx context.Context, id model.SessionID) (*model.Session, error) {
+	output, err := r.client.GetSession(ctx, &bedrockagentruntime.GetSessionInput{
+		SessionIdentifier: lo.ToPtr(id.String()),
+	})
+	var notFound *agtypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return nil, fmt.Errorf("session %s: %w", id, model.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to call GetSession: %w", err)
+	}
+	session := model.Session{
+		ID:        model.SessionID(lo.FromPtr(output.SessionId)),
+		Arn:       lo.FromPtr(output.SessionArn),
+		Status:    model.SessionStatus(output.SessionStatus),
+		CreatedAt: lo.FromPtr(output.CreatedAt),
+		UpdatedAt: lo.FromPtr(output.LastUpdatedAt),
+	}
+	session.SetMetadata(output.SessionMetadata)
+	return &session, nil
+}
+
+func (r *sessionRepository) EndSession(ctx context.Context, id model.SessionID) (*model.Session, error) {
+	if _, err := r.client.EndSession(ctx, &bedrockagentruntime.EndSessionInput{
+		SessionIdentifier: lo.ToPtr(id.String()),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to call EndSession: %w", err)
+	}
+	// EndSession はメタデータを返さないため取得し直す
+	return r.GetSession(ctx, id)
+}
+
+func (r *sessionRepository) DeleteSession(ctx context.Context, id model.SessionID) error {
+	if _, err := r.client.DeleteSession(ctx, &bedrockagentruntime.DeleteSessionInput{
+		SessionIdentifier: lo.ToPtr(id.String()),
+	}); err != nil {
+		return fmt.Errorf("failed to call DeleteSession: %w", err)
+	}
+	return nil
+}
+
+// ListSessions returns one page of sessions. ListSessions does not return metadata, so each session is
+// fetched (concurrently, with a bound) to resolve its owner. With a UserID filter, Bedrock pages are read
+// until the page is full; after maxScannedSessions a shorter page is returned with a NextToken to resume.
+func (r *sessionRepository) ListSessions(ctx context.Context, opts model.ListSessionsOptions) (*model.SessionPage, error) {
+	page := &model.SessionPage{Sessions: []model.Session{}}
+	nextToken := opts.NextToken
+	for scanned := 0; ; {
+		// 残り件数だけ取得し、絞り込み後にページから溢れて読み飛ばすことがないようにする
+		remaining := opts.MaxResults - int32(len(page.Sessions))
+		output, err := r.client.ListSessions(ctx, &bedrockagentruntime.ListSessionsInput{
+			MaxResults: lo.Ternary(opts.MaxResults > 0, lo.ToPtr(remaining), nil),
+			NextToken:  lo.Ternary(nextToken != "", lo.ToPtr(nextToken), nil),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to call ListSessions: %w", err)
+		}
+		sessions, err := r.getSessions(ctx, output.SessionSummaries)
+		if err != nil {
+			return nil, err
+		}
+		page.Sessions = append(page.Sessions, lo.Filter(sessions, func(s model.Session, _ int) bool {
+			return opts.UserID == "" || s.UserID == opts.UserID
+		})...)
+		nextToken = lo.FromPtr(output.NextToken)
+		scanned += len(output.SessionSummaries)
+		if nextToken == "" || opts.MaxResults <= 0 || int32(len(page.Sessions)) >= opts.MaxResults || scanned >= maxScannedSessions {
+			break
+		}
+	}
+	page.NextToken = nextToken
+	return page, nil
+}
+
+// getSessions fetches the sessions in summaries, preserving their order.
+func (r *sessionRepository) getSessions(ctx context.Context, summaries []agtypes.SessionSummary) ([]model.Session, error) {
+	sessions := make([]model.Session, len(summaries))
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(sessionMetadataConcurrency)
+	for i, s := range summaries {
+		g.Go(func() error {
+			session, err := r.GetSession(ctx, model.SessionID(lo.FromPtr(s.SessionId)))
+			if err != nil {
+				return err
+			}
+			sessions[i] = *session
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
@@ -0,0 +1,130 @@
+package infrastructure
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+)
+
+// fakeBedrockSessions emulates ListSessions and GetSession of the Bedrock session API.
+type fakeBedrockSessions struct {
+	owners   []string // セッション i の所有者
+	inflight atomic.Int32
+	peak     atomic.Int32
+	mu       sync.Mutex
+	pageSize []int
+}
+
+func (f *fakeBedrockSessions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC).Format(time.RFC3339)
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/sessions"), "/")
+	if r.Method == http.MethodPost && id == "" {
+		start, _ := strconv.Atoi(r.URL.Query().Get("nextToken"))
+		size, err := strconv.Atoi(r.URL.Query().Get("maxResults"))
+		if err != nil {
+			size = 1000
+		}
+		f.mu.Lock()
+		f.pageSize = append(f.pageSize, size)
+		f.mu.Unlock()
+		end := min(start+size, len(f.owners))
+		summaries := []map[string]any{}
+		for i := start; i < end; i++ {
+			summaries = append(summaries, map[string]any{
+				"sessionId": fmt.Sprintf("s%02d", i), "sessionArn": "arn", "sessionStatus": "ACTIVE", "createdAt": now, "lastUpdatedAt": now,
+			})
+		}
+		out := map[string]any{"sessionSummaries": summaries}
+		if end < len(f.owners) {
+			out["nextToken"] = strconv.Itoa(end)
+		}
+		_ = json.NewEncoder(w).Encode(out)
+		return
+	}
+
+	n := f.inflight.Add(1)
+	defer f.inflight.Add(-1)
+	for {
+		peak := f.peak.Load()
+		if n <= peak || f.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	i, _ := strconv.Atoi(strings.TrimPrefix(id, "s"))
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"sessionId": id, "sessionArn": "arn", "sessionStatus": "ACTIVE", "createdAt": now, "lastUpdatedAt": now,
+		"sessionMetadata": map[string]string{model.SessionMetadataUserID: f.owners[i]},
+	})
+}
+
+func TestSessionRepositoryListSessions(t *testing.T) {
+	fake := &fakeBedrockSessions{}
+	for i := range 30 {
+		fake.owners = append(fake.owners, map[bool]string{true: "alice", false: "bob"}[i%3 == 0])
+	}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	r := NewSessionRepository(bedrockagentruntime.New(bedrockagentruntime.Options{
+		Region:       "ap-northeast-1",
+		BaseEndpoint: aws.String(srv.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	}))
+
+	var got []model.SessionID
+	opts := model.ListSessionsOptions{UserID: "alice", MaxResults: 4}
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("paging did not terminate")
+		}
+		page, err := r.ListSessions(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("ListSessions: %v", err)
+		}
+		// 最終ページ以外は満杯で返す
+		if page.NextToken != "" && len(page.Sessions) != int(opts.MaxResults) {
+			t.Errorf("page %d holds %d sessions with a next token, want %d", pages, len(page.Sessions), opts.MaxResults)
+		}
+		for _, s := range page.Sessions {
+			if s.UserID != "alice" {
+				t.Errorf("session %s of %s listed for alice", s.ID, s.UserID)
+			}
+			got = append(got, s.ID)
+		}
+		if page.NextToken == "" {
+			break
+		}
+		opts.NextToken = page.NextToken
+	}
+
+	var want []model.SessionID
+	for i, owner := range fake.owners {
+		if owner == "alice" {
+			want = append(want, model.SessionID(fmt.Sprintf("s%02d", i)))
+		}
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("sessions = %v, want %v", got, want)
+	}
+	for _, size := range fake.pageSize {
+		if size > int(opts.MaxResults) {
+			t.Errorf("requested a Bedrock page of %d, more than the page size %d", size, opts.MaxResults)
+		}
+	}
+	if peak := fake.peak.Load(); peak > sessionMetadataConcurrency {
+		t.Errorf("%d concurrent GetSession calls, want at most %d", peak, sessionMetadataConcurrency)
+	}
+}
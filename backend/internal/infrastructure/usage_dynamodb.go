@@ -0,0 +1,55 @@
+package infrastructure
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/samber/lo"
+)
+
+// テーブル設計: pk="USER#<user_id>", sk="<created_at>#<message id>"
+// 月次の按分は pk と begins_with(sk, "2006-01") の Query で集計できる
+type usageDynamoDBRepository struct {
+	client *dynamodb.Client
+	table  string
+}
+
+type usageItem struct {
+	PK    string `json:"pk"`
+	SK    string `json:"sk"`
+	Month string `json:"month"`
+	model.UsageRecord
+}
+
+func NewUsageDynamoDBRepository(config *config.Config, client *dynamodb.Client) repository.UsageRepository {
+	return &usageDynamoDBRepository{
+		client: client,
+		table:  config.UsageTable,
+	}
+}
+
+func (r *usageDynamoDBRepository) Record(ctx context.Context, record model.UsageRecord) error {
+	createdAt := record.CreatedAt.UTC()
+	item, err := marshalDynamoDBItem(usageItem{
+		PK:          "USER#" + lo.CoalesceOrEmpty(record.UserID, "anonymous"),
+		SK:          createdAt.Format(time.RFC3339Nano) + "#" + record.MessageID,
+		Month:       createdAt.Format("2006-01"),
+		UsageRecord: record,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.table),
+		Item:      item,
+	}); err != nil {
+		return fmt.Errorf("put usage: %w", err)
+	}
+	return nil
+}
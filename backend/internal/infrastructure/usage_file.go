@@ -0,0 +1,30 @@
+package infrastructure
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type usageFileRepository struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewUsageFileRepository appends usage records to a JSON Lines file.
+func NewUsageFileRepository(path string) (repository.UsageRepository, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create usage dir: %w", err)
+	}
+	return &usageFileRepository{path: path}, nil
+}
+
+func (r *usageFileRepository) Record(_ context.Context, record model.UsageRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return appendJSONLine(r.path, record)
+}
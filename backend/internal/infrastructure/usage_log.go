@@ -0,0 +1,20 @@
+package infrastructure
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"context"
+	"log/slog"
+)
+
+type usageLogRepository struct{}
+
+// NewUsageLogRepository writes usage records to the application log.
+func NewUsageLogRepository() repository.UsageRepository {
+	return &usageLogRepository{}
+}
+
+func (r *usageLogRepository) Record(ctx context.Context, record model.UsageRecord) error {
+	slog.InfoContext(ctx, "usage", "record", record)
+	return nil
+}
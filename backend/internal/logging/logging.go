@@ -0,0 +1,108 @@
+// Package logging configures the structured (JSON) application logger.
+package logging
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Setup installs a JSON slog logger as the default logger. Records logged with a
+// context carry its request ID, session ID, user and trace ID.
+// The standard log package is redirected to the same logger.
+func Setup(config *config.Config) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.LogLevel)); err != nil {
+		return nil, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+	}
+	logger := New(os.Stdout, level, config.LogRedact)
+	slog.SetDefault(logger)
+	return logger, nil
+}
+
+func SetupMust(config *config.Config) *slog.Logger {
+	logger, err := Setup(config)
+	if err != nil {
+		panic(err)
+	}
+	return logger
+}
+
+// New creates a JSON logger writing to w.
+func New(w io.Writer, level slog.Level, redact bool) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if redact {
+		opts.ReplaceAttr = redactAttr
+	}
+	return slog.New(&contextHandler{Handler: slog.NewJSONHandler(w, opts)})
+}
+
+type fieldsKey struct{}
+
+// リクエスト単位でログに付与する値
+type fields struct {
+	requestID string
+	sessionID string
+}
+
+func fieldsFromContext(ctx context.Context) fields {
+	f, _ := ctx.Value(fieldsKey{}).(fields)
+	return f
+}
+
+// WithRequestID returns a context whose log records carry the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	f := fieldsFromContext(ctx)
+	f.requestID = id
+	return context.WithValue(ctx, fieldsKey{}, f)
+}
+
+// RequestIDFromContext returns the request ID stored by WithRequestID, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	return fieldsFromContext(ctx).requestID
+}
+
+// WithSessionID returns a context whose log records carry the session ID.
+func WithSessionID(ctx context.Context, id model.SessionID) context.Context {
+	f := fieldsFromContext(ctx)
+	f.sessionID = id.String()
+	return context.WithValue(ctx, fieldsKey{}, f)
+}
+
+// contextHandler adds the request-scoped fields of the record's context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		f := fieldsFromContext(ctx)
+		if f.requestID != "" {
+			r.AddAttrs(slog.String("request_id", f.requestID))
+		}
+		if f.sessionID != "" {
+			r.AddAttrs(slog.String("session_id", f.sessionID))
+		}
+		if user := model.IdentityFromContext(ctx).UserID(); user != "" {
+			r.AddAttrs(slog.String("user", user))
+		}
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// 値をそのまま出力しない属性名（小文字で比較）
+var sensitiveKeys = map[string]struct{}{
+	"authorization":        {},
+	"cookie":               {},
+	"set-cookie":           {},
+	"password":             {},
+	"secret":               {},
+	"token":                {},
+	"access_token":         {},
+	"refresh_token":        {},
+	"id_token":             {},
+	"api_key":              {},
+	"apikey":               {},
+	"x-amz-security-token": {},
+}
+
+// redactRule masks secrets and PII embedded in free text (messages, errors, SDK logs).
+type redactRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+var redactRules = []redactRule{
+	// HTTP ヘッダ（SDK のリクエストログ）
+	{regexp.MustCompile(`(?i)\b(authorization|x-amz-security-token|cookie|set-cookie):[^\r\n]*`), "$1: " + redacted},
+	{regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`), "Bearer " + redacted},
+	// JWT
+	{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), redacted},
+	// AWS アクセスキーID
+	{regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), redacted},
+	// メールアドレス
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[EMAIL]"},
+	// 電話番号（ハイフン区切り）
+	{regexp.MustCompile(`\b0\d{1,4}-\d{1,4}-\d{3,4}\b`), "[PHONE]"},
+}
+
+// Redact masks secrets and PII in s.
+func Redact(s string) string {
+	for _, r := range redactRules {
+		s = r.pattern.ReplaceAllString(s, r.replacement)
+	}
+	return s
+}
+
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if _, ok := sensitiveKeys[strings.ToLower(a.Key)]; ok {
+		return slog.String(a.Key, redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindAny:
+		// エラーメッセージには入力値が含まれることがある
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Redact(err.Error()))
+		}
+	}
+	return a
+}
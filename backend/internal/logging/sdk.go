@@ -0,0 +1,31 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	smithylogging "github.com/aws/smithy-go/logging"
+)
+
+type sdkLogger struct {
+	ctx context.Context
+}
+
+// SDKLogger adapts the AWS SDK logger to the default slog logger.
+func SDKLogger() smithylogging.Logger {
+	return sdkLogger{ctx: context.Background()}
+}
+
+func (l sdkLogger) Logf(classification smithylogging.Classification, format string, v ...any) {
+	level := slog.LevelInfo
+	if classification == smithylogging.Warn {
+		level = slog.LevelWarn
+	}
+	slog.Log(l.ctx, level, fmt.Sprintf(format, v...), "component", "aws-sdk")
+}
+
+// WithContext lets the SDK attach the request-scoped fields of the calling context.
+func (l sdkLogger) WithContext(ctx context.Context) smithylogging.Logger {
+	return sdkLogger{ctx: ctx}
+}
@@ -0,0 +1,112 @@
+// Package metrics defines the Prometheus metrics of the agent runtime server.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/smithy-go"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "chatbot"
+
+// ストリームは数十秒かかるため、既定のバケットより長めに取る
+var latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 8, 13, 21, 34, 55}
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	TimeToFirstToken = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "stream_time_to_first_token_seconds",
+		Help:      "Time from the Bedrock call to the first answer text of a stream.",
+		Buckets:   latencyBuckets,
+	}, []string{"knowledge_base"})
+
+	StreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "stream_duration_seconds",
+		Help:      "Total duration of answer streams by finish reason.",
+		Buckets:   latencyBuckets,
+	}, []string{"knowledge_base", "finish_reason"})
+
+	ActiveStreams = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sse_active_streams",
+		Help:      "SSE streams currently open.",
+	})
+
+	bedrockErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bedrock_errors_total",
+		Help:      "Errors returned by Bedrock calls by operation and error code.",
+	}, []string{"operation", "code"})
+
+	CitationsPerAnswer = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "citations_per_answer",
+		Help:      "Distinct references cited per answer.",
+		Buckets:   []float64{0, 1, 2, 3, 5, 8, 13, 21},
+	})
+
+	HeartbeatWrites = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sse_heartbeats_total",
+		Help:      "Heartbeat comments written to SSE streams.",
+	})
+
+	ClientDisconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sse_client_disconnects_total",
+		Help:      "SSE streams ended because the client disconnected.",
+	})
+)
+
+// Handler serves GET /metrics in the Prometheus exposition format. The labels name knowledge bases
+// and models, so it is served on its own listener (METRICS_ADDR) rather than the public API.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	return mux
+}
+
+// Middleware counts requests by route template (not raw path, to bound cardinality).
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).Inc()
+	}
+}
+
+// ObserveBedrockError counts a failed Bedrock call by its AWS error code.
+func ObserveBedrockError(operation string, err error) {
+	bedrockErrors.WithLabelValues(operation, errorCode(err)).Inc()
+}
+
+func errorCode(err error) string {
+	var apiErr smithy.APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.ErrorCode()
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "unknown"
+	}
+}
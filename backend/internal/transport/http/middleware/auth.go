@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/transport/http/sse"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/MicahParks/keyfunc/v3"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 許可する署名アルゴリズム（alg=none や HMAC による鍵混同を防ぐ）
+var allowedSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+type Authenticator interface {
+	// Authenticate verifies a bearer token and returns the caller it identifies.
+	Authenticate(ctx context.Context, token string) (*model.Identity, error)
+}
+
+type jwtAuthenticator struct {
+	config  *config.Config
+	keyfunc keyfunc.Keyfunc
+	parser  *jwt.Parser
+}
+
+// NewAuthenticator verifies JWTs against a JWKS fetched (and refreshed) from AUTH_JWKS_URL
+// or read once from AUTH_JWKS_FILE.
+func NewAuthenticator(config *config.Config) (Authenticator, error) {
+	var (
+		k   keyfunc.Keyfunc
+		err error
+	)
+	if config.AuthJWKSFile != "" {
+		raw, rerr := os.ReadFile(config.AuthJWKSFile)
+		if rerr != nil {
+			return nil, fmt.Errorf("read jwks file: %w", rerr)
+		}
+		k, err = keyfunc.NewJWKSetJSON(raw)
+	} else {
+		k, err = keyfunc.NewDefaultCtx(context.Background(), []string{config.AuthJWKSURL})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load jwks: %w", err)
+	}
+	return &jwtAuthenticator{
+		config:  config,
+		keyfunc: k,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods(allowedSigningMethods),
+			jwt.WithIssuer(config.AuthIssuer),
+			jwt.WithAudience(config.AuthAudience),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(config.AuthLeeway),
+		),
+	}, nil
+}
+
+func NewAuthenticatorMust(config *config.Config) Authenticator {
+	a, err := NewAuthenticator(config)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func (a *jwtAuthenticator) Authenticate(ctx context.Context, token string) (*model.Identity, error) {
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(token, claims, a.keyfunc.KeyfuncCtx(ctx)); err != nil {
+		return nil, fmt.Errorf("%w: %w", model.ErrUnauthenticated, err)
+	}
+	subject, _ := claims[a.config.AuthUserClaim].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing %q claim", model.ErrUnauthenticated, a.config.AuthUserClaim)
+	}
+	tenantID, _ := claims[a.config.AuthTenantClaim].(string)
+	return &model.Identity{
+		Subject:  subject,
+		TenantID: tenantID,
+		Groups:   stringsClaim(claims[a.config.AuthGroupsClaim]),
+	}, nil
+}
+
+// Auth rejects requests without a valid bearer token and stores the caller's identity
+// in the request context (see model.IdentityFromContext).
+func Auth(authenticator Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			abortUnauthenticated(c, `Bearer realm="api"`)
+			return
+		}
+		identity, err := authenticator.Authenticate(c.Request.Context(), strings.TrimSpace(token))
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "token rejected", "error", err)
+			abortUnauthenticated(c, `Bearer realm="api", error="invalid_token"`)
+			return
+		}
+		c.Request = c.Request.WithContext(model.WithIdentity(c.Request.Context(), identity))
+		c.Next()
+	}
+}
+
+func abortUnauthenticated(c *gin.Context, challenge string) {
+	info := sse.LookupErrorInfo(sse.ErrCodeUnauthenticated)
+	c.Header("WWW-Authenticate", challenge)
+	c.AbortWithStatusJSON(info.HTTPStatus, gin.H{"error": info.Message, "code": info.Code, "retryable": info.Retryable})
+}
+
+// stringsClaim accepts either a JSON array of strings or a single space-separated string.
+func stringsClaim(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
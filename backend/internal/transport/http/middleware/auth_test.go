@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "https://issuer.example.com"
+	testAudience = "chatbot"
+	testKeyID    = "test-key"
+)
+
+// newTestAuthenticator writes a JWKS holding the public half of key to a temporary file
+// and loads it the same way AUTH_JWKS_FILE does.
+func newTestAuthenticator(t *testing.T, key *rsa.PrivateKey) Authenticator {
+	t.Helper()
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": testKeyID,
+		"alg": "RS256",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAuthenticator(&config.Config{
+		AuthJWKSFile:    path,
+		AuthIssuer:      testIssuer,
+		AuthAudience:    testAudience,
+		AuthUserClaim:   "sub",
+		AuthGroupsClaim: "groups",
+		AuthTenantClaim: "tenant_id",
+	})
+	if err != nil {
+		t.Fatalf("NewAuthenticator: %v", err)
+	}
+	return a
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":       testIssuer,
+		"aud":       testAudience,
+		"sub":       "alice",
+		"tenant_id": "acme",
+		"groups":    []string{"hr", "sales"},
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testKeyID
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestAuthenticate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newTestAuthenticator(t, key)
+
+	with := func(k string, v any) jwt.MapClaims {
+		c := validClaims()
+		c[k] = v
+		return c
+	}
+	unsigned := func(method jwt.SigningMethod, signKey any) string {
+		token := jwt.NewWithClaims(method, validClaims())
+		token.Header["kid"] = testKeyID
+		s, err := token.SignedString(signKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	t.Run("valid token", func(t *testing.T) {
+		identity, err := a.Authenticate(context.Background(), signRS256(t, key, validClaims()))
+		if err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+		if identity.Subject != "alice" || identity.TenantID != "acme" || len(identity.Groups) != 2 {
+			t.Errorf("identity = %+v", identity)
+		}
+	})
+
+	rejected := map[string]string{
+		"wrong issuer":    signRS256(t, key, with("iss", "https://evil.example.com")),
+		"wrong audience":  signRS256(t, key, with("aud", "other")),
+		"expired":         signRS256(t, key, with("exp", time.Now().Add(-time.Hour).Unix())),
+		"missing expiry":  signRS256(t, key, func() jwt.MapClaims { c := validClaims(); delete(c, "exp"); return c }()),
+		"missing subject": signRS256(t, key, with("sub", "")),
+		"unknown key":     signRS256(t, otherKey, validClaims()),
+		"alg none":        unsigned(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType),
+		// 公開鍵（JWKS の n）を HMAC の秘密鍵として使う鍵混同攻撃
+		"hmac": unsigned(jwt.SigningMethodHS256, key.N.Bytes()),
+	}
+	for name, token := range rejected {
+		t.Run(name, func(t *testing.T) {
+			if _, err := a.Authenticate(context.Background(), token); !errors.Is(err, model.ErrUnauthenticated) {
+				t.Errorf("Authenticate = %v, want ErrUnauthenticated", err)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := gin.New()
+	e.Use(Auth(newTestAuthenticator(t, key)))
+	e.GET("/me", func(c *gin.Context) {
+		c.String(http.StatusOK, model.IdentityFromContext(c.Request.Context()).UserID())
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantBody      string
+	}{
+		{name: "valid token", authorization: "Bearer " + signRS256(t, key, validClaims()), wantStatus: http.StatusOK, wantBody: "alice"},
+		{name: "no header", wantStatus: http.StatusUnauthorized},
+		{name: "not bearer", authorization: "Basic YWxpY2U6cGFzcw==", wantStatus: http.StatusUnauthorized},
+		{name: "invalid token", authorization: "Bearer not-a-jwt", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("missing WWW-Authenticate header")
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
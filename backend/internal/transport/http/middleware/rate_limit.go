@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/transport/http/sse"
+	"aws-s3-knowledge-chatbot/backend/internal/usecase"
+	"context"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UsageTokensKey is the gin context key where handlers report the estimated tokens an invocation
+// consumed (see model.Usage), settled against the daily quotas after the handler returns.
+const UsageTokensKey = "usage_tokens"
+
+// RateLimit enforces the per-IP, per-tenant and per-user token buckets and daily quotas.
+// Must run after Auth so that the caller's identity is known.
+func RateLimit(rateLimitUsecase usecase.RateLimitUsecase) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subject := rateLimitSubject(c)
+		decision, err := rateLimitUsecase.Allow(c.Request.Context(), subject)
+		if err != nil {
+			// ストアの障害で API 全体を止めないよう、判定できない場合は通す
+			slog.ErrorContext(c.Request.Context(), "rate limit check failed", "error", err)
+		} else if !decision.Allowed {
+			abortRateLimited(c, decision)
+			return
+		}
+
+		c.Next()
+
+		if tokens := c.GetInt64(UsageTokensKey); tokens > 0 {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), 5*time.Second)
+			defer cancel()
+			if err := rateLimitUsecase.SettleUsage(ctx, subject, tokens); err != nil {
+				slog.ErrorContext(ctx, "settle usage failed", "error", err)
+			}
+		}
+	}
+}
+
+// ConcurrentStreams caps the number of simultaneous streams per caller.
+func ConcurrentStreams(rateLimitUsecase usecase.RateLimitUsecase) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		release, decision, err := rateLimitUsecase.AcquireStream(c.Request.Context(), rateLimitSubject(c))
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "acquire stream slot failed", "error", err)
+		} else if !decision.Allowed {
+			abortRateLimited(c, decision)
+			return
+		}
+		defer release()
+		c.Next()
+	}
+}
+
+func rateLimitSubject(c *gin.Context) model.RateLimitSubject {
+	identity := model.IdentityFromContext(c.Request.Context())
+	s := model.RateLimitSubject{IP: c.ClientIP()}
+	if identity != nil {
+		s.UserID = identity.Subject
+		s.TenantID = identity.TenantID
+	}
+	return s
+}
+
+func abortRateLimited(c *gin.Context, decision model.RateLimitDecision) {
+	info := sse.LookupErrorInfo(sse.ErrCodeRateLimited)
+	c.Header("Retry-After", strconv.Itoa(max(1, int(math.Ceil(decision.RetryAfter.Seconds())))))
+	c.AbortWithStatusJSON(info.HTTPStatus, gin.H{"error": info.Message, "code": info.Code, "retryable": info.Retryable, "reason": decision.Reason})
+}
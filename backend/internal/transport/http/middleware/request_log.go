@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/logging"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oklog/ulid/v2"
+)
+
+// RequestIDHeader carries the request ID between the client, the proxy and this server.
+const RequestIDHeader = "X-Request-Id"
+
+// 呼び出し元が指定した ID はログに出力するため、形式と長さを制限する
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID reuses the caller's X-Request-Id (or assigns a new one), echoes it in the
+// response and attaches it to the request context for logging.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = ulid.Make().String()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// AccessLog logs one structured record per request. The query string is omitted
+// because it may contain user identifiers.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startedAt := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		// 認証・セッションの情報は後続のハンドラが c.Request に設定する
+		slog.LogAttrs(c.Request.Context(), level, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.Duration("latency", time.Since(startedAt)),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
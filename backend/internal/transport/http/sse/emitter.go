@@ -1,6 +1,7 @@
 package sse
 
 import (
+	"aws-s3-knowledge-chatbot/backend/internal/metrics"
 	"bufio"
 	"context"
 	"encoding/json"
@@ -112,7 +113,9 @@ func (e *Emitter) StartHeartbeat(interval time.Duration) (stop func()) {
 			case <-e.ctx.Done():
 				return
 			case <-t.C:
-				_ = e.Comment("ping")
+				if e.Comment("ping") == nil {
+					metrics.HeartbeatWrites.Inc()
+				}
 			}
 		}
 	}()
@@ -132,6 +135,12 @@ func (e *Emitter) EmitMessageStart(role Role, opts ...EventOption) error {
 	return e.Emit(string(EventMessageStart), ev, opts...)
 }
 
+// EmitMessageStartWithHeader sends "message.start" with full header metadata.
+func (e *Emitter) EmitMessageStartWithHeader(header AIMessageHeader, opts ...EventOption) error {
+	ev := NewAIMessageStartWithHeader(header, opts...)
+	return e.Emit(string(EventMessageStart), ev, opts...)
+}
+
 // EmitMessageDelta sends "message.delta".
 func (e *Emitter) EmitMessageDelta(delta string, opts ...EventOption) error {
 	ev := NewAIMessageDelta(delta, opts...)
@@ -144,6 +153,18 @@ func (e *Emitter) EmitMessageCitation(refs []CitationReference, opts ...EventOpt
 	return e.Emit(string(EventMessageCitation), ev, opts...)
 }
 
+// EmitMessageGuardrail sends "message.guardrail".
+func (e *Emitter) EmitMessageGuardrail(action, assessment string, opts ...EventOption) error {
+	ev := NewAIMessageGuardrail(action, assessment, opts...)
+	return e.Emit(string(EventMessageGuardrail), ev, opts...)
+}
+
+// EmitMessageDebug sends "message.debug".
+func (e *Emitter) EmitMessageDebug(name string, data any, opts ...EventOption) error {
+	ev := NewAIMessageDebug(name, data, opts...)
+	return e.Emit(string(EventMessageDebug), ev, opts...)
+}
+
 // EmitMessageEnd sends "message.end".
 func (e *Emitter) EmitMessageEnd(reason AIEventFinishReason, opts ...EventOption) error {
 	ev := NewAIMessageEnd(reason, opts...)
@@ -155,3 +176,9 @@ func (e *Emitter) EmitError(message string, opts ...EventOption) error {
 	ev := NewAIError(message, opts...)
 	return e.Emit(string(EventError), ev, opts...)
 }
+
+// EmitErrorInfo sends "error" with a client-safe code and retry hint.
+func (e *Emitter) EmitErrorInfo(info ErrorInfo, opts ...EventOption) error {
+	ev := NewAIErrorFromInfo(info, opts...)
+	return e.Emit(string(EventError), ev, opts...)
+}
@@ -0,0 +1,107 @@
+package sse
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/aws/smithy-go"
+)
+
+// ErrorCode is a stable, client-safe error code sent in "error" events.
+type ErrorCode string
+
+const (
+	ErrCodeInvalidRequest     ErrorCode = "invalid_request"
+	ErrCodeUnauthenticated    ErrorCode = "unauthenticated"
+	ErrCodeAccessDenied       ErrorCode = "access_denied"
+	ErrCodeNotFound           ErrorCode = "not_found"
+	ErrCodeConflict           ErrorCode = "conflict"
+	ErrCodeRateLimited        ErrorCode = "rate_limited"
+	ErrCodeTimeout            ErrorCode = "timeout"
+	ErrCodeCanceled           ErrorCode = "canceled"
+	ErrCodeServiceUnavailable ErrorCode = "service_unavailable"
+	ErrCodeInternal           ErrorCode = "internal_error"
+)
+
+// ErrorInfo is the client-facing classification of an error.
+type ErrorInfo struct {
+	Code       ErrorCode
+	Message    string
+	Retryable  bool
+	HTTPStatus int
+}
+
+// クライアントへ返すのは固定文言のみ（AWS の内部情報を漏らさない）
+var errorInfos = map[ErrorCode]ErrorInfo{
+	ErrCodeInvalidRequest:     {Code: ErrCodeInvalidRequest, Message: "The request was rejected as invalid.", HTTPStatus: http.StatusBadRequest},
+	ErrCodeUnauthenticated:    {Code: ErrCodeUnauthenticated, Message: "A valid bearer token is required.", HTTPStatus: http.StatusUnauthorized},
+	ErrCodeAccessDenied:       {Code: ErrCodeAccessDenied, Message: "Access to the requested resource was denied.", HTTPStatus: http.StatusForbidden},
+	ErrCodeNotFound:           {Code: ErrCodeNotFound, Message: "The requested resource was not found.", HTTPStatus: http.StatusNotFound},
+	ErrCodeConflict:           {Code: ErrCodeConflict, Message: "The resource is not in a state that allows this request.", HTTPStatus: http.StatusConflict},
+	ErrCodeRateLimited:        {Code: ErrCodeRateLimited, Message: "Too many requests. Please retry later.", Retryable: true, HTTPStatus: http.StatusTooManyRequests},
+	ErrCodeTimeout:            {Code: ErrCodeTimeout, Message: "The request timed out.", Retryable: true, HTTPStatus: http.StatusGatewayTimeout},
+	ErrCodeCanceled:           {Code: ErrCodeCanceled, Message: "The request was canceled.", HTTPStatus: http.StatusRequestTimeout},
+	ErrCodeServiceUnavailable: {Code: ErrCodeServiceUnavailable, Message: "The service is temporarily unavailable.", Retryable: true, HTTPStatus: http.StatusServiceUnavailable},
+	ErrCodeInternal:           {Code: ErrCodeInternal, Message: "An internal error occurred.", HTTPStatus: http.StatusInternalServerError},
+}
+
+// awsErrorCodes maps AWS SDK (Bedrock) error codes to client-safe error codes.
+var awsErrorCodes = map[string]ErrorCode{
+	"ValidationException":           ErrCodeInvalidRequest,
+	"ConflictException":             ErrCodeConflict,
+	"AccessDeniedException":         ErrCodeAccessDenied,
+	"UnrecognizedClientException":   ErrCodeAccessDenied,
+	"ExpiredTokenException":         ErrCodeAccessDenied,
+	"ResourceNotFoundException":     ErrCodeNotFound,
+	"ThrottlingException":           ErrCodeRateLimited,
+	"ServiceQuotaExceededException": ErrCodeRateLimited,
+	"ModelTimeoutException":         ErrCodeTimeout,
+	"InternalServerException":       ErrCodeServiceUnavailable,
+	"DependencyFailedException":     ErrCodeServiceUnavailable,
+	"BadGatewayException":           ErrCodeServiceUnavailable,
+	"ServiceUnavailableException":   ErrCodeServiceUnavailable,
+	"ModelNotReadyException":        ErrCodeServiceUnavailable,
+}
+
+// LookupErrorInfo returns the client-facing info registered for code.
+func LookupErrorInfo(code ErrorCode) ErrorInfo {
+	if info, ok := errorInfos[code]; ok {
+		return info
+	}
+	return errorInfos[ErrCodeInternal]
+}
+
+// ClassifyError maps an error (typically from the AWS SDK) to a client-safe ErrorInfo.
+func ClassifyError(err error) ErrorInfo {
+	switch {
+	case err == nil:
+		return LookupErrorInfo(ErrCodeInternal)
+	case errors.Is(err, context.DeadlineExceeded):
+		return LookupErrorInfo(ErrCodeTimeout)
+	case errors.Is(err, context.Canceled):
+		return LookupErrorInfo(ErrCodeCanceled)
+	case errors.Is(err, model.ErrUnauthenticated):
+		return LookupErrorInfo(ErrCodeUnauthenticated)
+	case errors.Is(err, model.ErrAccessDenied):
+		return LookupErrorInfo(ErrCodeAccessDenied)
+	case errors.Is(err, model.ErrNotFound):
+		return LookupErrorInfo(ErrCodeNotFound)
+	case errors.Is(err, model.ErrConflict):
+		return LookupErrorInfo(ErrCodeConflict)
+	case errors.Is(err, model.ErrInvalidArgument):
+		return LookupErrorInfo(ErrCodeInvalidRequest)
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if code, ok := awsErrorCodes[apiErr.ErrorCode()]; ok {
+			return LookupErrorInfo(code)
+		}
+		if apiErr.ErrorFault() == smithy.FaultServer {
+			return LookupErrorInfo(ErrCodeServiceUnavailable)
+		}
+	}
+	return LookupErrorInfo(ErrCodeInternal)
+}
@@ -0,0 +1,43 @@
+package sse
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+func TestClassifyError(t *testing.T) {
+	apiError := func(code string, fault smithy.ErrorFault) error {
+		return fmt.Errorf("operation error: %w", &smithy.GenericAPIError{Code: code, Message: "internal detail", Fault: fault})
+	}
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   ErrorCode
+		wantStatus int
+	}{
+		// 終了済み・処理中のセッションへの呼び出しは REST のセッション API と同じ 409 にする
+		{name: "conflict from Bedrock", err: apiError("ConflictException", smithy.FaultClient), wantCode: ErrCodeConflict, wantStatus: http.StatusConflict},
+		{name: "conflict from the domain", err: fmt.Errorf("session s1 is ENDED: %w", model.ErrConflict), wantCode: ErrCodeConflict, wantStatus: http.StatusConflict},
+		{name: "validation", err: apiError("ValidationException", smithy.FaultClient), wantCode: ErrCodeInvalidRequest, wantStatus: http.StatusBadRequest},
+		{name: "invalid argument", err: fmt.Errorf("filter: %w", model.ErrInvalidArgument), wantCode: ErrCodeInvalidRequest, wantStatus: http.StatusBadRequest},
+		{name: "not found", err: apiError("ResourceNotFoundException", smithy.FaultClient), wantCode: ErrCodeNotFound, wantStatus: http.StatusNotFound},
+		{name: "throttling", err: apiError("ThrottlingException", smithy.FaultClient), wantCode: ErrCodeRateLimited, wantStatus: http.StatusTooManyRequests},
+		{name: "unknown server fault", err: apiError("SomethingNew", smithy.FaultServer), wantCode: ErrCodeServiceUnavailable, wantStatus: http.StatusServiceUnavailable},
+		{name: "timeout", err: context.DeadlineExceeded, wantCode: ErrCodeTimeout, wantStatus: http.StatusGatewayTimeout},
+		{name: "unknown", err: errors.New("boom"), wantCode: ErrCodeInternal, wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := ClassifyError(tt.err)
+			if info.Code != tt.wantCode || info.HTTPStatus != tt.wantStatus {
+				t.Errorf("ClassifyError = %s/%d, want %s/%d", info.Code, info.HTTPStatus, tt.wantCode, tt.wantStatus)
+			}
+		})
+	}
+}
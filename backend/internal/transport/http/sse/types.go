@@ -1,5 +1,7 @@
 package sse
 
+import "aws-s3-knowledge-chatbot/backend/internal/domain/model"
+
 type AIEvent interface {
 	GetBase() *AIBaseEvent
 	GetType() AIEventType
@@ -17,11 +19,13 @@ const (
 type AIEventType string
 
 const (
-	EventMessageStart    AIEventType = "message.start"    // メッセージ開始（ヘッダ）
-	EventMessageDelta    AIEventType = "message.delta"    // トークン／チャンク差分
-	EventMessageEnd      AIEventType = "message.end"      // メッセージ終了（メタ／finish reason）
-	EventMessageCitation AIEventType = "message.citation" // 引用情報（RAG参照）
-	EventError           AIEventType = "error"            // エラー（非ストリーム時も共通）
+	EventMessageStart     AIEventType = "message.start"     // メッセージ開始（ヘッダ）
+	EventMessageDelta     AIEventType = "message.delta"     // トークン／チャンク差分
+	EventMessageEnd       AIEventType = "message.end"       // メッセージ終了（メタ／finish reason）
+	EventMessageCitation  AIEventType = "message.citation"  // 引用情報（RAG参照）
+	EventMessageGuardrail AIEventType = "message.guardrail" // ガードレール介入
+	EventMessageDebug     AIEventType = "message.debug"     // デバッグ情報（DEBUG 有効時のみ）
+	EventError            AIEventType = "error"             // エラー（非ストリーム時も共通）
 )
 
 type AIEventFinishReason string
@@ -44,7 +48,10 @@ type AIBaseEvent struct {
 }
 
 type AIMessageHeader struct {
-	Role Role `json:"role"`
+	Role            Role   `json:"role"`
+	ID              string `json:"id,omitempty"`                // メッセージID
+	Model           string `json:"model,omitempty"`             // 生成に利用したモデルARN
+	KnowledgeBaseID string `json:"knowledge_base_id,omitempty"` // 検索対象のナレッジベースID
 }
 
 type AIMessageStart struct {
@@ -77,8 +84,10 @@ func (d AIMessageDelta) GetType() AIEventType {
 
 type AIMessageEnd struct {
 	AIBaseEvent
-	Type         AIEventType         `json:"type"`          // "message.end"
-	FinishReason AIEventFinishReason `json:"finish_reason"` // "completed" など
+	Type         AIEventType         `json:"type"`            // "message.end"
+	FinishReason AIEventFinishReason `json:"finish_reason"`   // "completed" など
+	Model        string              `json:"model,omitempty"` // 生成に利用したモデルARN（コスト按分用）
+	Usage        *model.Usage        `json:"usage,omitempty"` // 推定の利用量とコスト
 }
 
 func (e AIMessageEnd) GetBase() *AIBaseEvent {
@@ -91,15 +100,35 @@ func (e AIMessageEnd) GetType() AIEventType {
 
 // CitationReference holds reference metadata and content/snippet.
 type CitationReference struct {
-	Text   string `json:"text,omitempty"`   // snippet of referenced text
-	Source string `json:"source,omitempty"` // e.g., s3://bucket/key or URL
+	Number       int            `json:"number,omitempty"`        // 脚注番号（[1], [2]...）
+	Text         string         `json:"text,omitempty"`          // snippet of referenced text
+	Source       string         `json:"source,omitempty"`        // e.g., s3://bucket/key or URL
+	LocationType string         `json:"location_type,omitempty"` // e.g., S3, WEB, CONFLUENCE
+	URL          string         `json:"url,omitempty"`           // ブラウザで開けるURL（S3 は署名付きURL）
+	Metadata     map[string]any `json:"metadata,omitempty"`      // retrieval metadata attributes
+}
+
+// CitationSpan locates the cited part of the generated response.
+type CitationSpan struct {
+	Text  string `json:"text,omitempty"`
+	Start int32  `json:"start"`
+	End   int32  `json:"end"`
+}
+
+// CitationMarker tells where footnote markers belong in the generated text.
+type CitationMarker struct {
+	Position int32 `json:"position"` // 回答テキスト中で脚注記号を挿入する位置
+	Numbers  []int `json:"numbers"`  // 挿入する脚注番号
 }
 
 // AIMessageCitation represents a citation event in SSE stream.
+// Refs には当該メッセージで初めて引用された参照のみが含まれる
 type AIMessageCitation struct {
 	AIBaseEvent
-	Type AIEventType         `json:"type"` // "message.citation"
-	Refs []CitationReference `json:"refs"`
+	Type   AIEventType         `json:"type"` // "message.citation"
+	Span   *CitationSpan       `json:"span,omitempty"`
+	Marker *CitationMarker     `json:"marker,omitempty"`
+	Refs   []CitationReference `json:"refs"`
 }
 
 func (c AIMessageCitation) GetBase() *AIBaseEvent {
@@ -110,6 +139,38 @@ func (c AIMessageCitation) GetType() AIEventType {
 	return c.Type
 }
 
+// AIMessageGuardrail represents a guardrail intervention in SSE stream.
+type AIMessageGuardrail struct {
+	AIBaseEvent
+	Type       AIEventType `json:"type"`                 // "message.guardrail"
+	Action     string      `json:"action"`               // e.g. "INTERVENED"
+	Assessment string      `json:"assessment,omitempty"` // UI表示用の判定内容
+}
+
+func (g AIMessageGuardrail) GetBase() *AIBaseEvent {
+	return &g.AIBaseEvent
+}
+
+func (g AIMessageGuardrail) GetType() AIEventType {
+	return g.Type
+}
+
+// AIMessageDebug carries diagnostic data, emitted only when debug mode is on.
+type AIMessageDebug struct {
+	AIBaseEvent
+	Type AIEventType `json:"type"` // "message.debug"
+	Name string      `json:"name"` // e.g. "orchestration_settings"
+	Data any         `json:"data,omitempty"`
+}
+
+func (d AIMessageDebug) GetBase() *AIBaseEvent {
+	return &d.AIBaseEvent
+}
+
+func (d AIMessageDebug) GetType() AIEventType {
+	return d.Type
+}
+
 type AIError struct {
 	AIBaseEvent
 	Type      AIEventType `json:"type"`
@@ -143,11 +204,18 @@ func WithSessionID(sessionID string) EventOption {
 // Required: role
 // Optional: use EventOption (WithID, WithSessionID)
 func NewAIMessageStart(role Role, opts ...EventOption) AIMessageStart {
+	return NewAIMessageStartWithHeader(AIMessageHeader{Role: role}, opts...)
+}
+
+// NewAIMessageStartWithHeader creates a message.start event with full header metadata.
+// Required: header (Role)
+// Optional: use EventOption (WithID, WithSessionID)
+func NewAIMessageStartWithHeader(header AIMessageHeader, opts ...EventOption) AIMessageStart {
 	ev := AIMessageStart{
 		AIBaseEvent: AIBaseEvent{},
 		Type:        EventMessageStart,
+		Message:     header,
 	}
-	ev.Message.Role = role
 	for _, opt := range opts {
 		opt(&ev.AIBaseEvent)
 	}
@@ -199,6 +267,15 @@ func NewAIError(message string, opts ...EventOption) AIError {
 	return ev
 }
 
+// NewAIErrorFromInfo creates an error event from a classified ErrorInfo.
+// Optional: use EventOption (WithID, WithSessionID)
+func NewAIErrorFromInfo(info ErrorInfo, opts ...EventOption) AIError {
+	ev := NewAIError(info.Message, opts...)
+	ev.Code = string(info.Code)
+	ev.Retryable = info.Retryable
+	return ev
+}
+
 func NewAssistantStart(opts ...EventOption) AIMessageStart {
 	return NewAIMessageStart(RoleAssistant, opts...)
 }
@@ -225,3 +302,35 @@ func NewAIMessageCitation(refs []CitationReference, opts ...EventOption) AIMessa
 func NewAssistantEnd(reason AIEventFinishReason, opts ...EventOption) AIMessageEnd {
 	return NewAIMessageEnd(reason, opts...)
 }
+
+// NewAIMessageGuardrail creates a message.guardrail event.
+// Required: action
+// Optional: use EventOption (WithID, WithSessionID)
+func NewAIMessageGuardrail(action, assessment string, opts ...EventOption) AIMessageGuardrail {
+	ev := AIMessageGuardrail{
+		AIBaseEvent: AIBaseEvent{},
+		Type:        EventMessageGuardrail,
+		Action:      action,
+		Assessment:  assessment,
+	}
+	for _, opt := range opts {
+		opt(&ev.AIBaseEvent)
+	}
+	return ev
+}
+
+// NewAIMessageDebug creates a message.debug event.
+// Required: name
+// Optional: use EventOption (WithID, WithSessionID)
+func NewAIMessageDebug(name string, data any, opts ...EventOption) AIMessageDebug {
+	ev := AIMessageDebug{
+		AIBaseEvent: AIBaseEvent{},
+		Type:        EventMessageDebug,
+		Name:        name,
+		Data:        data,
+	}
+	for _, opt := range opts {
+		opt(&ev.AIBaseEvent)
+	}
+	return ev
+}
@@ -0,0 +1,12 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+)
+
+// audit records who performed an action. Attributes are given as key/value pairs;
+// the caller is taken from the context by the logger.
+func audit(ctx context.Context, action string, kv ...any) {
+	slog.InfoContext(ctx, "audit", append([]any{"action", action}, kv...)...)
+}
@@ -1,77 +1,423 @@
 package usecase
 
 import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
 	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"aws-s3-knowledge-chatbot/backend/internal/metrics"
+	"aws-s3-knowledge-chatbot/backend/internal/tracing"
 	"aws-s3-knowledge-chatbot/backend/internal/transport/http/sse"
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/document"
 	atypes "github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+	"github.com/oklog/ulid/v2"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// RetrieveAndGenerate はガードレールのアクションのみを返すため、クライアント向けの判定内容は固定文言とする
+const guardrailAssessment = "The response was blocked by a content policy."
+
 type BedrockAgentRuntimeUsecase interface {
-	InvokeStream(ctx context.Context, sessionId, query string) (<-chan sse.AIEvent, error)
+	Search(ctx context.Context, query string, opts model.SearchOptions) (*SearchResult, error)
+	Invoke(ctx context.Context, sessionID model.SessionID, query string, opts model.InvokeOptions) (*InvokeResult, error)
+	InvokeStream(ctx context.Context, sessionID model.SessionID, query string, opts model.InvokeOptions) (<-chan sse.AIEvent, error)
+}
+
+// InvokeResult is the complete (non-streaming) answer of a knowledge base query.
+type InvokeResult struct {
+	SessionID    model.SessionID         `json:"session_id"`
+	Model        string                  `json:"model"`
+	Text         string                  `json:"text"`
+	Citations    []Citation              `json:"citations"`
+	References   []sse.CitationReference `json:"references"` // 脚注番号順の参照一覧（重複排除済み）
+	FinishReason sse.AIEventFinishReason `json:"finish_reason"`
+	Usage        model.Usage             `json:"usage"`
+}
+
+// SearchResult is a page of ranked knowledge base chunks.
+type SearchResult struct {
+	Results   []SearchHit `json:"results"`
+	NextToken string      `json:"next_token,omitempty"`
+}
+
+// SearchHit is a single chunk returned by the knowledge base.
+type SearchHit struct {
+	Score float64 `json:"score"`
+	sse.CitationReference
+}
+
+// Citation locates one cited span of the answer text and its footnote numbers.
+type Citation struct {
+	sse.CitationSpan
+	Marker sse.CitationMarker `json:"marker"`
 }
 
 type bedrockAgentRuntimeUsecase struct {
+	config                        *config.Config
 	bedrockAgentRuntimeRepository repository.BedrockAgentRuntimeRepository
+	documentRepository            repository.DocumentRepository // nil の場合は署名付きURLを付与しない
+	usageRepository               repository.UsageRepository
 }
 
 func NewBedrockAgentRuntimeUsecase(
+	config *config.Config,
 	bedrockAgentRuntimeRepository repository.BedrockAgentRuntimeRepository,
+	documentRepository repository.DocumentRepository,
+	usageRepository repository.UsageRepository,
 ) BedrockAgentRuntimeUsecase {
 	return &bedrockAgentRuntimeUsecase{
+		config:                        config,
 		bedrockAgentRuntimeRepository: bedrockAgentRuntimeRepository,
+		documentRepository:            documentRepository,
+		usageRepository:               usageRepository,
+	}
+}
+
+func (u *bedrockAgentRuntimeUsecase) Search(ctx context.Context, query string, opts model.SearchOptions) (_ *SearchResult, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Search", attribute.String("knowledge_base", opts.KnowledgeBase.Name))
+	defer func() { tracing.End(span, err) }()
+	audit(ctx, "search", "knowledge_base", opts.KnowledgeBase.Name)
+	res, err := u.bedrockAgentRuntimeRepository.Retrieve(ctx, query, opts)
+	if err != nil {
+		metrics.ObserveBedrockError("Retrieve", err)
+		return nil, err
 	}
+	return &SearchResult{
+		NextToken: lo.FromPtr(res.NextToken),
+		Results: lo.Map(res.RetrievalResults, func(r atypes.KnowledgeBaseRetrievalResult, _ int) SearchHit {
+			return SearchHit{
+				Score:             lo.FromPtr(r.Score),
+				CitationReference: u.toCitationReference(ctx, r.Content, r.Location, r.Metadata),
+			}
+		}),
+	}, nil
 }
 
-func (u *bedrockAgentRuntimeUsecase) InvokeStream(ctx context.Context, sessionId, query string) (<-chan sse.AIEvent, error) {
-	res, err := u.bedrockAgentRuntimeRepository.RetrieveAndGenerateStream(ctx, sessionId, query)
+func (u *bedrockAgentRuntimeUsecase) Invoke(ctx context.Context, sessionID model.SessionID, query string, opts model.InvokeOptions) (_ *InvokeResult, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Invoke", invokeAttributes(sessionID, opts)...)
+	defer func() { tracing.End(span, err) }()
+	audit(ctx, "invoke", "session", sessionID, "knowledge_base", opts.KnowledgeBase.Name, "model", opts.ResolvedModelArn())
+	res, err := u.bedrockAgentRuntimeRepository.RetrieveAndGenerate(ctx, sessionID, query, opts)
 	if err != nil {
+		metrics.ObserveBedrockError("RetrieveAndGenerate", err)
 		return nil, err
 	}
+	if res.Output == nil {
+		return nil, fmt.Errorf("nil output returned")
+	}
+
+	index := newCitationIndex()
+	citations := lo.Map(res.Citations, func(c atypes.Citation, _ int) Citation {
+		span := lo.FromPtr(toCitationSpan(c.GeneratedResponsePart))
+		numbers, _ := index.add(u.toCitationReferences(ctx, c.RetrievedReferences))
+		return Citation{
+			CitationSpan: span,
+			Marker:       sse.CitationMarker{Position: span.End, Numbers: numbers},
+		}
+	})
 
-	stream := res.GetStream()
-	if stream == nil {
-		return nil, fmt.Errorf("nil stream returned")
+	result := &InvokeResult{
+		SessionID:    model.SessionID(lo.FromPtr(res.SessionId)),
+		Model:        opts.ResolvedModelArn(),
+		Text:         lo.FromPtr(res.Output.Text),
+		FinishReason: lo.Ternary(res.GuardrailAction == atypes.GuadrailActionIntervened, sse.FinishGuardrail, sse.FinishCompleted),
+		Citations:    citations,
+		References:   index.references(),
 	}
+	metrics.CitationsPerAnswer.Observe(float64(len(result.References)))
+	span.SetAttributes(attribute.String("finish_reason", string(result.FinishReason)), attribute.Int("citations", len(result.References)))
+	result.Usage = u.estimateUsage(query, opts, result.References, utf8.RuneCountInString(result.Text))
+	u.recordUsage(ctx, model.UsageRecord{
+		MessageID:     ulid.Make().String(),
+		SessionID:     result.SessionID,
+		Model:         result.Model,
+		KnowledgeBase: opts.KnowledgeBase.Name,
+		FinishReason:  string(result.FinishReason),
+		Usage:         result.Usage,
+	})
+	return result, nil
+}
+
+func (u *bedrockAgentRuntimeUsecase) InvokeStream(ctx context.Context, sessionID model.SessionID, query string, invokeOpts model.InvokeOptions) (_ <-chan sse.AIEvent, err error) {
+	// スパンはストリームを読み終えるまで続くため、開始に失敗した場合のみここで閉じる
+	ctx, span := tracing.Start(ctx, "usecase.InvokeStream", invokeAttributes(sessionID, invokeOpts)...)
+	defer func() {
+		if err != nil {
+			tracing.End(span, err)
+		}
+	}()
+	audit(ctx, "invoke_stream", "session", sessionID, "knowledge_base", invokeOpts.KnowledgeBase.Name, "model", invokeOpts.ResolvedModelArn())
+	startedAt := time.Now()
+	res, err := u.bedrockAgentRuntimeRepository.RetrieveAndGenerateStream(ctx, sessionID, query, invokeOpts)
+	if err != nil {
+		metrics.ObserveBedrockError("RetrieveAndGenerateStream", err)
+		return nil, err
+	}
+
+	stream := res.Stream
 	if err := stream.Err(); err != nil {
 		return nil, fmt.Errorf("stream error: %w", err)
 	}
 
+	// 初回ターンでは Bedrock が採番したセッションIDをクライアントへ返す
+	messageID := ulid.Make().String()
+	opts := []sse.EventOption{
+		sse.WithID(messageID),
+		sse.WithSessionID(lo.CoalesceOrEmpty(lo.FromPtr(res.SessionID), sessionID.String())),
+	}
+	modelArn := invokeOpts.ResolvedModelArn()
+	index := newCitationIndex()
+	textLen := 0 // 送信済みテキストの文字数（span が無い引用の脚注位置・利用量に使う）
+	// 途中で終了した場合（切断・タイムアウト）も利用量は記録する
+	finishReason := sse.FinishError
+	newEnd := func(reason sse.AIEventFinishReason) sse.AIMessageEnd {
+		finishReason = reason
+		usage := u.estimateUsage(query, invokeOpts, index.references(), textLen)
+		ev := sse.NewAssistantEnd(reason, opts...)
+		ev.Model = modelArn
+		ev.Usage = &usage
+		return ev
+	}
 	outputChan := make(chan sse.AIEvent)
 
 	go func() {
+		ctx, consume := tracing.Start(ctx, "stream.consume")
+		var streamErr error
+		// 出力チャネルを閉じた後に記録し、ストリームの終了を遅らせない
+		defer func() {
+			consume.SetAttributes(attribute.String("finish_reason", string(finishReason)), attribute.Int("citations", len(index.references())))
+			tracing.End(consume, streamErr)
+			tracing.End(span, streamErr)
+			metrics.StreamDuration.WithLabelValues(invokeOpts.KnowledgeBase.Name, string(finishReason)).Observe(time.Since(startedAt).Seconds())
+			metrics.CitationsPerAnswer.Observe(float64(len(index.references())))
+			u.recordUsage(ctx, model.UsageRecord{
+				MessageID:     messageID,
+				SessionID:     model.SessionID(lo.FromPtr(res.SessionID)),
+				Model:         modelArn,
+				KnowledgeBase: invokeOpts.KnowledgeBase.Name,
+				FinishReason:  string(finishReason),
+				Usage:         u.estimateUsage(query, invokeOpts, index.references(), textLen),
+			})
+		}()
 		defer func() {
 			// 明示クローズ＆出力チャネルを閉じる
 			_ = stream.Close()
 			close(outputChan)
 		}()
 
+		// 読み手（handler）が居なくなっても送信でブロックし続けないよう ctx を監視する
+		send := func(ev sse.AIEvent) bool {
+			select {
+			case outputChan <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// 最初の delta より前にメッセージのヘッダを通知する
+		if !send(sse.NewAIMessageStartWithHeader(sse.AIMessageHeader{
+			Role:            sse.RoleAssistant,
+			ID:              messageID,
+			Model:           modelArn,
+			KnowledgeBaseID: invokeOpts.KnowledgeBase.KnowledgeBaseID,
+		}, opts...)) {
+			return
+		}
+
+		if u.config.Debug && invokeOpts.Orchestration != nil {
+			// RetrieveAndGenerate(Stream) は書き換え後のクエリもトレースも返さないため、入力クエリと適用した設定のみ通知する
+			if !send(sse.NewAIMessageDebug("orchestration_settings", orchestrationDebug(query, invokeOpts.Orchestration), opts...)) {
+				return
+			}
+		}
+
 		cnt := 0
+		firstToken := false
 		for ev := range stream.Events() {
 			cnt++
 			switch e := ev.(type) {
 			case *atypes.RetrieveAndGenerateStreamResponseOutputMemberOutput:
-				if e.Value.Text != nil {
-					outputChan <- sse.NewAssistantDelta(lo.FromPtr(e.Value.Text))
+				if e.Value.Text == nil {
+					continue
+				}
+				if !firstToken {
+					firstToken = true
+					consume.AddEvent("first_token")
+					metrics.TimeToFirstToken.WithLabelValues(invokeOpts.KnowledgeBase.Name).Observe(time.Since(startedAt).Seconds())
+				}
+				textLen += utf8.RuneCountInString(*e.Value.Text)
+				if !send(sse.NewAssistantDelta(lo.FromPtr(e.Value.Text), opts...)) {
+					return
 				}
 			case *atypes.RetrieveAndGenerateStreamResponseOutputMemberCitation:
-				outputChan <- sse.NewAIMessageCitation(lo.Map(e.Value.RetrievedReferences, func(ref atypes.RetrievedReference, _ int) sse.CitationReference {
-					return sse.CitationReference{
-						Text:   lo.FromPtr(ref.Content.Text),
-						Source: lo.FromPtr(ref.Location.S3Location.Uri),
-					}
-				}))
+				numbers, added := index.add(u.toCitationReferences(ctx, e.Value.RetrievedReferences))
+				citation := sse.NewAIMessageCitation(added, opts...)
+				citation.Span = toCitationSpan(e.Value.GeneratedResponsePart)
+				position := int32(textLen)
+				if citation.Span != nil {
+					position = citation.Span.End
+				}
+				citation.Marker = &sse.CitationMarker{Position: position, Numbers: numbers}
+				consume.AddEvent("citation", trace.WithAttributes(attribute.Int("position", int(position)), attribute.IntSlice("numbers", numbers)))
+				if !send(citation) {
+					return
+				}
 			case *atypes.RetrieveAndGenerateStreamResponseOutputMemberGuardrail:
-				log.Printf("[stream] guardrail: %+v\n", e.Value)
+				consume.AddEvent("guardrail", trace.WithAttributes(attribute.String("action", string(e.Value.Action))))
+				if e.Value.Action != atypes.GuadrailActionIntervened {
+					continue
+				}
+				// 介入後の回答は途中までしか無いため、ここでメッセージを終了する
+				if send(sse.NewAIMessageGuardrail(string(e.Value.Action), guardrailAssessment, opts...)) {
+					send(newEnd(sse.FinishGuardrail))
+				}
+				return
 			default:
-				log.Printf("[stream] unknown event: %T %+v\n", e, e)
+				slog.WarnContext(ctx, "unknown stream event", "type", fmt.Sprintf("%T", e))
 			}
 		}
+
+		// イベントチャネルのクローズは正常終了とは限らない（スロットリング等）
+		if err := stream.Err(); err != nil {
+			streamErr = err
+			metrics.ObserveBedrockError("RetrieveAndGenerateStream", err)
+			if ctx.Err() == nil && send(toAIError(ctx, err, opts...)) {
+				send(newEnd(sse.FinishError))
+			}
+			return
+		}
+		send(newEnd(sse.FinishCompleted))
 	}()
 
 	return outputChan, nil
 }
+
+func invokeAttributes(sessionID model.SessionID, opts model.InvokeOptions) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("session.id", sessionID.String()),
+		attribute.String("knowledge_base", opts.KnowledgeBase.Name),
+		attribute.String("model", opts.ResolvedModelArn()),
+	}
+}
+
+// orchestrationDebug describes the orchestration applied to a query. It carries the input
+// query, not the rewritten one: Bedrock does not expose the rewritten query, and
+// rewritten_query_available says so explicitly so clients don't look for it.
+func orchestrationDebug(query string, o *model.OrchestrationOptions) map[string]any {
+	data := map[string]any{
+		"input_query":               query,
+		"rewritten_query_available": false,
+		"query_decomposition":       o.QueryDecomposition,
+	}
+	if o.PromptTemplate != nil {
+		data["prompt_template"] = o.PromptTemplate.Name
+	}
+	if o.Inference != nil {
+		data["inference"] = o.Inference
+	}
+	return data
+}
+
+func (u *bedrockAgentRuntimeUsecase) toCitationReferences(ctx context.Context, refs []atypes.RetrievedReference) []sse.CitationReference {
+	return lo.Map(refs, func(ref atypes.RetrievedReference, _ int) sse.CitationReference {
+		return u.toCitationReference(ctx, ref.Content, ref.Location, ref.Metadata)
+	})
+}
+
+func (u *bedrockAgentRuntimeUsecase) toCitationReference(
+	ctx context.Context,
+	content *atypes.RetrievalResultContent,
+	location *atypes.RetrievalResultLocation,
+	metadata map[string]document.Interface,
+) sse.CitationReference {
+	ref := sse.CitationReference{Metadata: toMetadata(metadata)}
+	if content != nil {
+		ref.Text = lo.FromPtr(content.Text)
+	}
+	if location == nil {
+		return ref
+	}
+	ref.LocationType = string(location.Type)
+	ref.Source = locationURI(location)
+	if location.Type != atypes.RetrievalResultLocationTypeS3 {
+		// S3 以外は元のURLをそのまま開ける
+		ref.URL = lo.Ternary(strings.HasPrefix(ref.Source, "https://"), ref.Source, "")
+		return ref
+	}
+	if u.documentRepository != nil && ref.Source != "" {
+		url, err := u.documentRepository.PresignURL(ctx, ref.Source)
+		if err != nil {
+			slog.WarnContext(ctx, "presign citation failed", "source", ref.Source, "error", err)
+		}
+		ref.URL = url
+	}
+	return ref
+}
+
+// locationURI returns the identifier of a retrieved document for any data source type.
+func locationURI(l *atypes.RetrievalResultLocation) string {
+	switch {
+	case l.S3Location != nil:
+		return lo.FromPtr(l.S3Location.Uri)
+	case l.WebLocation != nil:
+		return lo.FromPtr(l.WebLocation.Url)
+	case l.ConfluenceLocation != nil:
+		return lo.FromPtr(l.ConfluenceLocation.Url)
+	case l.SalesforceLocation != nil:
+		return lo.FromPtr(l.SalesforceLocation.Url)
+	case l.SharePointLocation != nil:
+		return lo.FromPtr(l.SharePointLocation.Url)
+	case l.KendraDocumentLocation != nil:
+		return lo.FromPtr(l.KendraDocumentLocation.Uri)
+	case l.CustomDocumentLocation != nil:
+		return lo.FromPtr(l.CustomDocumentLocation.Id)
+	case l.SqlLocation != nil:
+		return lo.FromPtr(l.SqlLocation.Query)
+	default:
+		return ""
+	}
+}
+
+func toCitationSpan(part *atypes.GeneratedResponsePart) *sse.CitationSpan {
+	if part == nil || part.TextResponsePart == nil {
+		return nil
+	}
+	span := &sse.CitationSpan{Text: lo.FromPtr(part.TextResponsePart.Text)}
+	if part.TextResponsePart.Span != nil {
+		span.Start = lo.FromPtr(part.TextResponsePart.Span.Start)
+		span.End = lo.FromPtr(part.TextResponsePart.Span.End)
+	}
+	return span
+}
+
+// toMetadata decodes Bedrock metadata documents into plain JSON values.
+func toMetadata(m map[string]document.Interface) map[string]any {
+	if len(m) == 0 {
+		return nil
+	}
+	return lo.MapValues(m, func(d document.Interface, key string) any {
+		var v any
+		if d == nil {
+			return nil
+		}
+		if err := d.UnmarshalSmithyDocument(&v); err != nil {
+			slog.Warn("decode metadata failed", "key", key, "error", err)
+			return nil
+		}
+		return v
+	})
+}
+
+func toAIError(ctx context.Context, err error, opts ...sse.EventOption) sse.AIError {
+	slog.ErrorContext(ctx, "stream failed", "error", err)
+	return sse.NewAIErrorFromInfo(sse.ClassifyError(err), opts...)
+}
@@ -0,0 +1,290 @@
+package usecase
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"aws-s3-knowledge-chatbot/backend/internal/transport/http/sse"
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	atypes "github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+	"github.com/aws/smithy-go"
+	"github.com/samber/lo"
+)
+
+// fakeStreamReader replays events and records whether the stream was closed.
+type fakeStreamReader struct {
+	events chan atypes.RetrieveAndGenerateStreamResponseOutput
+	closed atomic.Bool
+	err    error
+}
+
+func (r *fakeStreamReader) Events() <-chan atypes.RetrieveAndGenerateStreamResponseOutput {
+	return r.events
+}
+
+func (r *fakeStreamReader) Close() error {
+	r.closed.Store(true)
+	return nil
+}
+
+// Err reports err once the replayed events have been read, as when a stream fails midway.
+func (r *fakeStreamReader) Err() error {
+	if len(r.events) > 0 {
+		return nil
+	}
+	return r.err
+}
+
+type fakeBedrockAgentRuntimeRepository struct {
+	repository.BedrockAgentRuntimeRepository
+	stream *fakeStreamReader
+}
+
+func (r *fakeBedrockAgentRuntimeRepository) RetrieveAndGenerateStream(context.Context, model.SessionID, string, model.InvokeOptions) (*repository.RetrieveAndGenerateStreamOutput, error) {
+	return &repository.RetrieveAndGenerateStreamOutput{SessionID: lo.ToPtr("session-1"), Stream: r.stream}, nil
+}
+
+type fakeUsageRepository struct{}
+
+func (fakeUsageRepository) Record(context.Context, model.UsageRecord) error { return nil }
+
+var _ bedrockagentruntime.RetrieveAndGenerateStreamResponseOutputReader = (*fakeStreamReader)(nil)
+
+// newFakeStream returns a stream that replays events and then ends.
+func newFakeStream(events ...atypes.RetrieveAndGenerateStreamResponseOutput) *fakeStreamReader {
+	stream := &fakeStreamReader{events: make(chan atypes.RetrieveAndGenerateStreamResponseOutput, len(events))}
+	for _, ev := range events {
+		stream.events <- ev
+	}
+	close(stream.events)
+	return stream
+}
+
+// invokeStream runs InvokeStream over stream and collects every event until the output channel closes.
+func invokeStream(t *testing.T, stream *fakeStreamReader, query string) []sse.AIEvent {
+	t.Helper()
+	u := NewBedrockAgentRuntimeUsecase(&config.Config{CharactersPerToken: 3}, &fakeBedrockAgentRuntimeRepository{stream: stream}, nil, fakeUsageRepository{})
+	ch, err := u.InvokeStream(context.Background(), "", query, model.InvokeOptions{})
+	if err != nil {
+		t.Fatalf("InvokeStream: %v", err)
+	}
+	var events []sse.AIEvent
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return events
+			}
+			events = append(events, ev)
+		case <-timeout:
+			t.Fatalf("output channel was not closed; got %d events", len(events))
+		}
+	}
+}
+
+func textDelta(text string) *atypes.RetrieveAndGenerateStreamResponseOutputMemberOutput {
+	return &atypes.RetrieveAndGenerateStreamResponseOutputMemberOutput{Value: atypes.RetrieveAndGenerateOutputEvent{Text: lo.ToPtr(text)}}
+}
+
+// eventTypes lists the event types in order, e.g. "message.start", "message.delta".
+func eventTypes(events []sse.AIEvent) []string {
+	return lo.Map(events, func(ev sse.AIEvent, _ int) string { return string(ev.GetType()) })
+}
+
+func TestInvokeStreamEndsOnGuardrailIntervention(t *testing.T) {
+	events := invokeStream(t, newFakeStream(
+		textDelta("partial"),
+		&atypes.RetrieveAndGenerateStreamResponseOutputMemberGuardrail{Value: atypes.GuardrailEvent{Action: atypes.GuadrailActionIntervened}},
+		// 介入後に届いた出力は送らない
+		textDelta("after"),
+	), "question")
+
+	want := []string{"message.start", "message.delta", "message.guardrail", "message.end"}
+	if got := eventTypes(events); !slices.Equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	if g := events[2].(sse.AIMessageGuardrail); g.Action != string(atypes.GuadrailActionIntervened) {
+		t.Errorf("guardrail action = %q, want %q", g.Action, atypes.GuadrailActionIntervened)
+	}
+	if end := events[3].(sse.AIMessageEnd); end.FinishReason != sse.FinishGuardrail {
+		t.Errorf("finish_reason = %q, want %q", end.FinishReason, sse.FinishGuardrail)
+	}
+}
+
+func TestInvokeStreamStopsWhenContextIsCanceled(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	stream := &fakeStreamReader{events: make(chan atypes.RetrieveAndGenerateStreamResponseOutput, 2)}
+	stream.events <- &atypes.RetrieveAndGenerateStreamResponseOutputMemberOutput{Value: atypes.RetrieveAndGenerateOutputEvent{Text: lo.ToPtr("hello")}}
+	stream.events <- &atypes.RetrieveAndGenerateStreamResponseOutputMemberOutput{Value: atypes.RetrieveAndGenerateOutputEvent{Text: lo.ToPtr("world")}}
+	u := NewBedrockAgentRuntimeUsecase(&config.Config{CharactersPerToken: 3}, &fakeBedrockAgentRuntimeRepository{stream: stream}, nil, fakeUsageRepository{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := u.InvokeStream(ctx, "", "question", model.InvokeOptions{})
+	if err != nil {
+		t.Fatalf("InvokeStream: %v", err)
+	}
+	// message.start のみ受け取り、以降は読まずにキャンセルする（クライアント切断を模す）
+	<-ch
+	cancel()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !stream.closed.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("stream was not closed after the context was canceled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// 生成側の goroutine は出力チャネルを閉じて終了する
+	for range ch {
+	}
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines leaked: before=%d after=%d", before, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestInvokeStreamReportsMidStreamError(t *testing.T) {
+	stream := newFakeStream(textDelta("partial"))
+	stream.err = &smithy.GenericAPIError{Code: "ThrottlingException", Message: "rate exceeded for account 123456789012", Fault: smithy.FaultClient}
+	events := invokeStream(t, stream, "question")
+
+	want := []string{"message.start", "message.delta", "error", "message.end"}
+	if got := eventTypes(events); !slices.Equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	e := events[2].(sse.AIError)
+	if e.Code != string(sse.ErrCodeRateLimited) || !e.Retryable {
+		t.Errorf("error = %+v, want a retryable %s", e, sse.ErrCodeRateLimited)
+	}
+	// AWS のエラーメッセージ（アカウントIDなど）はクライアントへ渡さない
+	if strings.Contains(e.Message, "123456789012") || e.Message != sse.LookupErrorInfo(sse.ErrCodeRateLimited).Message {
+		t.Errorf("error message = %q, want the fixed client-safe message", e.Message)
+	}
+	if end := events[3].(sse.AIMessageEnd); end.FinishReason != sse.FinishError {
+		t.Errorf("finish_reason = %q, want %q", end.FinishReason, sse.FinishError)
+	}
+	if !stream.closed.Load() {
+		t.Error("stream was not closed")
+	}
+}
+
+func citationEvent(span *atypes.Span, spanText string, refs ...atypes.RetrievedReference) *atypes.RetrieveAndGenerateStreamResponseOutputMemberCitation {
+	ev := atypes.CitationEvent{RetrievedReferences: refs}
+	if span != nil {
+		ev.GeneratedResponsePart = &atypes.GeneratedResponsePart{TextResponsePart: &atypes.TextResponsePart{Text: lo.ToPtr(spanText), Span: span}}
+	}
+	return &atypes.RetrieveAndGenerateStreamResponseOutputMemberCitation{Value: ev}
+}
+
+func retrievedReference(uri, text string) atypes.RetrievedReference {
+	return atypes.RetrievedReference{
+		Content: &atypes.RetrievalResultContent{Text: lo.ToPtr(text)},
+		Location: &atypes.RetrievalResultLocation{
+			Type:       atypes.RetrievalResultLocationTypeS3,
+			S3Location: &atypes.RetrievalResultS3Location{Uri: lo.ToPtr(uri)},
+		},
+	}
+}
+
+func TestInvokeStreamNumbersRepeatedCitations(t *testing.T) {
+	a := retrievedReference("s3://docs/a.pdf", "chunk a")
+	b := retrievedReference("s3://docs/b.pdf", "chunk b")
+	c := retrievedReference("s3://docs/c.pdf", "chunk c")
+	events := invokeStream(t, newFakeStream(
+		textDelta("有給休暇は20日。"),
+		citationEvent(&atypes.Span{Start: lo.ToPtr[int32](0), End: lo.ToPtr[int32](9)}, "有給休暇は20日。", a, b),
+		textDelta("繰越は翌年まで。"),
+		// b は既出のため番号 2 を再利用し、c のみ新しく 3 を振る
+		citationEvent(&atypes.Span{Start: lo.ToPtr[int32](9), End: lo.ToPtr[int32](17)}, "繰越は翌年まで。", b, c),
+		textDelta("詳細は規程を参照。"),
+		// span の無い引用は送信済みテキストの末尾に脚注を付ける
+		citationEvent(nil, "", a, a),
+	), "question")
+
+	var text strings.Builder
+	var citations []sse.AIMessageCitation
+	for _, ev := range events {
+		switch e := ev.(type) {
+		case sse.AIMessageDelta:
+			text.WriteString(e.Delta)
+		case sse.AIMessageCitation:
+			citations = append(citations, e)
+		}
+	}
+	if len(citations) != 3 {
+		t.Fatalf("got %d citation events, want 3", len(citations))
+	}
+	answer := []rune(text.String())
+
+	tests := []struct {
+		wantMarker sse.CitationMarker
+		wantRefs   map[int]string // 初出の参照: 脚注番号 -> 引用元
+	}{
+		{wantMarker: sse.CitationMarker{Position: 9, Numbers: []int{1, 2}}, wantRefs: map[int]string{1: "s3://docs/a.pdf", 2: "s3://docs/b.pdf"}},
+		{wantMarker: sse.CitationMarker{Position: 17, Numbers: []int{2, 3}}, wantRefs: map[int]string{3: "s3://docs/c.pdf"}},
+		{wantMarker: sse.CitationMarker{Position: int32(len(answer)), Numbers: []int{1}}, wantRefs: map[int]string{}},
+	}
+	for i, tt := range tests {
+		got := citations[i]
+		if got.Marker == nil || got.Marker.Position != tt.wantMarker.Position || !slices.Equal(got.Marker.Numbers, tt.wantMarker.Numbers) {
+			t.Errorf("citation %d marker = %+v, want %+v", i, got.Marker, tt.wantMarker)
+		}
+		refs := lo.SliceToMap(got.Refs, func(r sse.CitationReference) (int, string) { return r.Number, r.Source })
+		if fmt.Sprint(refs) != fmt.Sprint(tt.wantRefs) {
+			t.Errorf("citation %d refs = %v, want %v", i, refs, tt.wantRefs)
+		}
+		// span は回答テキスト（文字単位）の該当箇所を指す
+		if s := got.Span; s != nil && string(answer[s.Start:s.End]) != s.Text {
+			t.Errorf("citation %d span %d:%d = %q, want %q", i, s.Start, s.End, string(answer[s.Start:s.End]), s.Text)
+		}
+	}
+}
+
+func TestInvokeStreamAttachesUsageToEnd(t *testing.T) {
+	events := invokeStream(t, newFakeStream(
+		textDelta("回答です。"),
+		citationEvent(nil, "", retrievedReference("s3://docs/a.pdf", "chunk a")),
+	), "質問です")
+
+	end, ok := events[len(events)-1].(sse.AIMessageEnd)
+	if !ok || end.FinishReason != sse.FinishCompleted {
+		t.Fatalf("last event = %+v, want message.end completed", events[len(events)-1])
+	}
+	// 入力は質問4文字＋引用チャンク7文字、出力は5文字（CHARACTERS_PER_TOKEN=3）
+	want := model.Usage{InputCharacters: 11, OutputCharacters: 5, InputTokens: 4, OutputTokens: 2}
+	if end.Usage == nil || *end.Usage != want {
+		t.Fatalf("usage = %+v, want %+v", end.Usage, want)
+	}
+	// クォータはこの値で精算される
+	if got := end.Usage.TotalTokens(); got != 6 {
+		t.Errorf("TotalTokens = %d, want 6", got)
+	}
+}
+
+func TestOrchestrationDebugDoesNotClaimRewrittenQuery(t *testing.T) {
+	data := orchestrationDebug("質問です", &model.OrchestrationOptions{QueryDecomposition: true})
+
+	if data["input_query"] != "質問です" {
+		t.Errorf("input_query = %v, want the query as sent", data["input_query"])
+	}
+	// Bedrock は書き換え後のクエリを返さない
+	if data["rewritten_query_available"] != false {
+		t.Errorf("rewritten_query_available = %v, want false", data["rewritten_query_available"])
+	}
+	if _, ok := data["rewritten_query"]; ok {
+		t.Error("rewritten_query must not be set")
+	}
+}
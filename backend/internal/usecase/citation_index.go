@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/transport/http/sse"
+	"slices"
+)
+
+// citationIndex assigns stable footnote numbers ([1], [2]...) to the references cited in one message.
+// 同じ引用元・同じチャンクは同じ番号にまとめる
+type citationIndex struct {
+	numbers map[string]int
+	refs    []sse.CitationReference
+}
+
+func newCitationIndex() *citationIndex {
+	return &citationIndex{numbers: map[string]int{}}
+}
+
+// add numbers refs and returns the footnote numbers they map to, plus the references seen for the first time.
+func (x *citationIndex) add(refs []sse.CitationReference) (numbers []int, added []sse.CitationReference) {
+	for _, ref := range refs {
+		key := ref.Source + "\x00" + ref.Text
+		n, ok := x.numbers[key]
+		if !ok {
+			n = len(x.refs) + 1
+			x.numbers[key] = n
+			ref.Number = n
+			x.refs = append(x.refs, ref)
+			added = append(added, ref)
+		}
+		if !slices.Contains(numbers, n) {
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers, added
+}
+
+// references returns every numbered reference in footnote order.
+func (x *citationIndex) references() []sse.CitationReference {
+	return x.refs
+}
@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/samber/lo"
+)
+
+type ConversationUsecase interface {
+	// SaveMessages persists messages in order, filling in missing IDs and timestamps.
+	SaveMessages(ctx context.Context, messages ...model.Message) error
+	ListSessions(ctx context.Context, userID string) ([]model.SessionSummary, error)
+	ListMessages(ctx context.Context, sessionID model.SessionID) ([]model.Message, error)
+}
+
+type conversationUsecase struct {
+	conversationRepository repository.ConversationRepository
+}
+
+func NewConversationUsecase(conversationRepository repository.ConversationRepository) ConversationUsecase {
+	return &conversationUsecase{
+		conversationRepository: conversationRepository,
+	}
+}
+
+func (u *conversationUsecase) SaveMessages(ctx context.Context, messages ...model.Message) error {
+	for i := range messages {
+		// ULID は生成順にソートされるため、保存順がそのまま時系列順になる
+		if messages[i].ID == "" {
+			messages[i].ID = ulid.Make().String()
+		}
+		if messages[i].CreatedAt.IsZero() {
+			messages[i].CreatedAt = time.Now()
+		}
+	}
+	return u.conversationRepository.AppendMessages(ctx, messages...)
+}
+
+func (u *conversationUsecase) ListSessions(ctx context.Context, userID string) ([]model.SessionSummary, error) {
+	// 認証済みの場合は自分の会話のみ
+	if identity := model.IdentityFromContext(ctx); identity != nil {
+		userID = identity.Subject
+	}
+	return u.conversationRepository.ListSessions(ctx, userID)
+}
+
+func (u *conversationUsecase) ListMessages(ctx context.Context, sessionID model.SessionID) ([]model.Message, error) {
+	messages, err := u.conversationRepository.ListMessages(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	identity := model.IdentityFromContext(ctx)
+	if identity == nil {
+		return messages, nil
+	}
+	// 所有者はメッセージ単位で判定し、他の利用者のメッセージは返さない
+	owned := lo.Filter(messages, func(m model.Message, _ int) bool {
+		return m.UserID == identity.Subject
+	})
+	if len(messages) > 0 && len(owned) == 0 {
+		return nil, fmt.Errorf("session %s: %w", sessionID, model.ErrNotFound)
+	}
+	return owned, nil
+}
@@ -0,0 +1,152 @@
+package usecase
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ストリームの最大継続時間（ハンドラのタイムアウト）より長くし、解放漏れ時の枠を自動回収する
+const streamSlotTTL = 2 * time.Minute
+
+type RateLimitUsecase interface {
+	// Allow consumes one request from the caller's token buckets and checks the daily quotas.
+	Allow(ctx context.Context, subject model.RateLimitSubject) (model.RateLimitDecision, error)
+	// AcquireStream reserves a concurrent stream slot. release must be called once the stream ends.
+	AcquireStream(ctx context.Context, subject model.RateLimitSubject) (release func(), decision model.RateLimitDecision, err error)
+	// SettleUsage records the estimated tokens consumed by a finished invocation against the daily quotas.
+	SettleUsage(ctx context.Context, subject model.RateLimitSubject, tokens int64) error
+}
+
+type rateLimitUsecase struct {
+	config              *config.Config
+	rateLimitRepository repository.RateLimitRepository
+}
+
+func NewRateLimitUsecase(
+	config *config.Config,
+	rateLimitRepository repository.RateLimitRepository,
+) RateLimitUsecase {
+	return &rateLimitUsecase{
+		config:              config,
+		rateLimitRepository: rateLimitRepository,
+	}
+}
+
+type bucketCheck struct {
+	reason string
+	key    string
+	limit  model.RateLimit
+}
+
+type quotaCheck struct {
+	reason string
+	key    string
+	limit  int64
+}
+
+func (u *rateLimitUsecase) Allow(ctx context.Context, subject model.RateLimitSubject) (model.RateLimitDecision, error) {
+	for _, b := range u.buckets(subject) {
+		ok, wait, err := u.rateLimitRepository.Take(ctx, b.key, b.limit)
+		if err != nil {
+			return model.RateLimitDecision{}, err
+		}
+		if !ok {
+			return model.RateLimitDecision{RetryAfter: wait, Reason: b.reason}, nil
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, q := range u.quotas(subject, now) {
+		used, err := u.rateLimitRepository.Usage(ctx, q.key)
+		if err != nil {
+			return model.RateLimitDecision{}, err
+		}
+		if used >= q.limit {
+			return model.RateLimitDecision{RetryAfter: nextDay(now).Sub(now), Reason: q.reason}, nil
+		}
+	}
+	return model.RateLimitDecision{Allowed: true}, nil
+}
+
+func (u *rateLimitUsecase) AcquireStream(ctx context.Context, subject model.RateLimitSubject) (func(), model.RateLimitDecision, error) {
+	noop := func() {}
+	if u.config.MaxConcurrentStreams <= 0 {
+		return noop, model.RateLimitDecision{Allowed: true}, nil
+	}
+	key := "streams:" + callerKey(subject)
+	ok, err := u.rateLimitRepository.Acquire(ctx, key, u.config.MaxConcurrentStreams, streamSlotTTL)
+	if err != nil {
+		return noop, model.RateLimitDecision{}, err
+	}
+	if !ok {
+		// 他のストリームの終了を待つ必要があるため、目安として数秒後の再試行を促す
+		return noop, model.RateLimitDecision{RetryAfter: 5 * time.Second, Reason: "concurrent_streams"}, nil
+	}
+	release := func() {
+		// 切断後でも確実に解放する
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := u.rateLimitRepository.Release(ctx, key); err != nil {
+			slog.ErrorContext(ctx, "release stream slot failed", "key", key, "error", err)
+		}
+	}
+	return release, model.RateLimitDecision{Allowed: true}, nil
+}
+
+func (u *rateLimitUsecase) SettleUsage(ctx context.Context, subject model.RateLimitSubject, tokens int64) error {
+	if tokens <= 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	for _, q := range u.quotas(subject, now) {
+		// 日付を跨いでも集計が残るよう、キーの有効期限は2日とする
+		if _, err := u.rateLimitRepository.AddUsage(ctx, q.key, tokens, 48*time.Hour); err != nil {
+			return fmt.Errorf("settle %s: %w", q.reason, err)
+		}
+	}
+	return nil
+}
+
+func (u *rateLimitUsecase) buckets(s model.RateLimitSubject) []bucketCheck {
+	var checks []bucketCheck
+	add := func(reason, id string, limit model.RateLimit) {
+		if id != "" && limit.Enabled() {
+			checks = append(checks, bucketCheck{reason: reason, key: "rate:" + reason + ":" + id, limit: limit})
+		}
+	}
+	add("ip", s.IP, model.RateLimit{Rate: u.config.RateLimitIPRate, Burst: u.config.RateLimitIPBurst})
+	add("tenant", s.TenantID, model.RateLimit{Rate: u.config.RateLimitTenantRate, Burst: u.config.RateLimitTenantBurst})
+	add("user", s.UserID, model.RateLimit{Rate: u.config.RateLimitUserRate, Burst: u.config.RateLimitUserBurst})
+	return checks
+}
+
+func (u *rateLimitUsecase) quotas(s model.RateLimitSubject, now time.Time) []quotaCheck {
+	day := now.Format(time.DateOnly)
+	var checks []quotaCheck
+	add := func(scope, id string, limit int64) {
+		if id != "" && limit > 0 {
+			checks = append(checks, quotaCheck{reason: scope + "_daily_quota", key: "quota:" + scope + ":" + id + ":" + day, limit: limit})
+		}
+	}
+	add("tenant", s.TenantID, u.config.QuotaDailyTenantTokens)
+	add("user", s.UserID, u.config.QuotaDailyUserTokens)
+	return checks
+}
+
+// callerKey identifies the caller for per-caller limits: the user, or the IP when unauthenticated.
+func callerKey(s model.RateLimitSubject) string {
+	if s.UserID != "" {
+		return "user:" + s.UserID
+	}
+	return "ip:" + s.IP
+}
+
+func nextDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+}
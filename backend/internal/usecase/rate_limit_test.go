@@ -0,0 +1,51 @@
+package usecase
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/infrastructure"
+	"context"
+	"testing"
+)
+
+func TestDailyTokenQuota(t *testing.T) {
+	ctx := context.Background()
+	u := NewRateLimitUsecase(&config.Config{
+		QuotaDailyUserTokens:   100,
+		QuotaDailyTenantTokens: 150,
+	}, infrastructure.NewRateLimitMemoryRepository())
+	alice := model.RateLimitSubject{IP: "192.0.2.1", UserID: "alice", TenantID: "acme"}
+	bob := model.RateLimitSubject{IP: "192.0.2.2", UserID: "bob", TenantID: "acme"}
+
+	allow := func(s model.RateLimitSubject) model.RateLimitDecision {
+		t.Helper()
+		d, err := u.Allow(ctx, s)
+		if err != nil {
+			t.Fatalf("Allow: %v", err)
+		}
+		return d
+	}
+
+	if err := u.SettleUsage(ctx, alice, 99); err != nil {
+		t.Fatal(err)
+	}
+	if d := allow(alice); !d.Allowed {
+		t.Fatalf("Allow under quota = %+v, want allowed", d)
+	}
+	if err := u.SettleUsage(ctx, alice, 1); err != nil {
+		t.Fatal(err)
+	}
+	if d := allow(alice); d.Allowed || d.Reason != "user_daily_quota" || d.RetryAfter <= 0 {
+		t.Fatalf("Allow at user quota = %+v, want user_daily_quota", d)
+	}
+	// 同じテナントの別ユーザーはテナントの上限に達するまで使える
+	if d := allow(bob); !d.Allowed {
+		t.Fatalf("Allow(bob) = %+v, want allowed", d)
+	}
+	if err := u.SettleUsage(ctx, bob, 50); err != nil {
+		t.Fatal(err)
+	}
+	if d := allow(bob); d.Allowed || d.Reason != "tenant_daily_quota" {
+		t.Fatalf("Allow at tenant quota = %+v, want tenant_daily_quota", d)
+	}
+}
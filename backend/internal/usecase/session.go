@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/samber/lo"
+)
+
+type SessionUsecase interface {
+	// CreateSession starts a session on kb, which the caller must be allowed to use.
+	CreateSession(ctx context.Context, kb model.KnowledgeBase, session model.Session) (*model.Session, error)
+	GetSession(ctx context.Context, id model.SessionID) (*model.Session, error)
+	EndSession(ctx context.Context, id model.SessionID) (*model.Session, error)
+	// DeleteSession deletes the Bedrock session and its stored conversation history.
+	DeleteSession(ctx context.Context, id model.SessionID) error
+	ListSessions(ctx context.Context, opts model.ListSessionsOptions) (*model.SessionPage, error)
+	// ContinueSession checks that an invocation may continue the session. A zero ID starts a new session,
+	// and IDs unknown to both Bedrock and the conversation history are left for Bedrock to resolve.
+	ContinueSession(ctx context.Context, id model.SessionID) error
+}
+
+type sessionUsecase struct {
+	config                 *config.Config
+	sessionRepository      repository.SessionRepository
+	conversationRepository repository.ConversationRepository
+}
+
+func NewSessionUsecase(
+	config *config.Config,
+	sessionRepository repository.SessionRepository,
+	conversationRepository repository.ConversationRepository,
+) SessionUsecase {
+	return &sessionUsecase{
+		config:                 config,
+		sessionRepository:      sessionRepository,
+		conversationRepository: conversationRepository,
+	}
+}
+
+func (u *sessionUsecase) CreateSession(ctx context.Context, kb model.KnowledgeBase, session model.Session) (*model.Session, error) {
+	identity := model.IdentityFromContext(ctx)
+	// 呼び出し時と同じく、利用を許可されていないナレッジベースのセッションは作らせない
+	if !kb.Allows(identity.Callers()...) {
+		return nil, fmt.Errorf("knowledge base %q: %w", kb.Name, model.ErrAccessDenied)
+	}
+	session.KnowledgeBase = kb.Name
+	// 認証済みの場合、所有者はトークンの利用者で固定する
+	if identity != nil {
+		session.UserID = identity.Subject
+		session.TenantID = lo.CoalesceOrEmpty(identity.TenantID, session.TenantID)
+	}
+	created, err := u.sessionRepository.CreateSession(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	audit(ctx, "session.create", "session", created.ID, "knowledge_base", created.KnowledgeBase)
+	return created, nil
+}
+
+func (u *sessionUsecase) GetSession(ctx context.Context, id model.SessionID) (*model.Session, error) {
+	return u.ownedSession(ctx, id)
+}
+
+func (u *sessionUsecase) EndSession(ctx context.Context, id model.SessionID) (*model.Session, error) {
+	if _, err := u.ownedSession(ctx, id); err != nil {
+		return nil, err
+	}
+	audit(ctx, "session.end", "session", id)
+	return u.sessionRepository.EndSession(ctx, id)
+}
+
+func (u *sessionUsecase) DeleteSession(ctx context.Context, id model.SessionID) error {
+	if _, err := u.ownedSession(ctx, id); err != nil {
+		return err
+	}
+	audit(ctx, "session.delete", "session", id)
+	if err := u.sessionRepository.DeleteSession(ctx, id); err != nil {
+		return err
+	}
+	return u.conversationRepository.DeleteMessages(ctx, id)
+}
+
+func (u *sessionUsecase) ListSessions(ctx context.Context, opts model.ListSessionsOptions) (*model.SessionPage, error) {
+	if identity := model.IdentityFromContext(ctx); identity != nil {
+		opts.UserID = identity.Subject
+	}
+	return u.sessionRepository.ListSessions(ctx, opts)
+}
+
+func (u *sessionUsecase) ContinueSession(ctx context.Context, id model.SessionID) error {
+	if id.IsZero() {
+		return nil
+	}
+	if u.config.SessionAPIEnabled {
+		session, err := u.sessionRepository.GetSession(ctx, id)
+		switch {
+		case err == nil:
+			// 他の利用者のセッションは存在を明かさない
+			if !ownedBy(ctx, session.UserID) {
+				return fmt.Errorf("session %s: %w", id, model.ErrNotFound)
+			}
+			if session.Status != model.SessionStatusActive {
+				return fmt.Errorf("session %s is %s: %w", id, session.Status, model.ErrConflict)
+			}
+			return nil
+		case !errors.Is(err, model.ErrNotFound):
+			return err
+		}
+		// RetrieveAndGenerate が暗黙に採番したセッションはセッション管理 API に無いため、会話履歴から判定する
+	}
+	return u.continueConversation(ctx, id)
+}
+
+// continueConversation rejects a session whose stored history belongs to another caller. A session
+// missing from the history (lost on restart, served by another replica or never saved) is not rejected,
+// so that a history store outage never breaks an ongoing conversation.
+func (u *sessionUsecase) continueConversation(ctx context.Context, id model.SessionID) error {
+	messages, err := u.conversationRepository.ListMessages(ctx, id)
+	if err != nil {
+		slog.WarnContext(ctx, "conversation history unavailable, skipping ownership check", "session", id, "error", err)
+		return nil
+	}
+	if lo.SomeBy(messages, func(m model.Message) bool { return !ownedBy(ctx, m.UserID) }) {
+		return fmt.Errorf("session %s: %w", id, model.ErrNotFound)
+	}
+	return nil
+}
+
+// ownedSession returns the session if the caller may access it. Other users' sessions are
+// reported as not found so that their existence is not revealed.
+func (u *sessionUsecase) ownedSession(ctx context.Context, id model.SessionID) (*model.Session, error) {
+	session, err := u.sessionRepository.GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ownedBy(ctx, session.UserID) {
+		return nil, fmt.Errorf("session %s: %w", id, model.ErrNotFound)
+	}
+	return session, nil
+}
+
+// ownedBy reports whether the caller owns a resource of userID. Without authentication
+// every caller is treated as the owner.
+func ownedBy(ctx context.Context, userID string) bool {
+	identity := model.IdentityFromContext(ctx)
+	return identity == nil || identity.Subject == userID
+}
@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/config"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/domain/repository"
+	"aws-s3-knowledge-chatbot/backend/internal/infrastructure"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// fakeSessionRepository serves GetSession from a fixed set of Bedrock sessions.
+type fakeSessionRepository struct {
+	repository.SessionRepository
+	sessions map[model.SessionID]model.Session
+	calls    int
+}
+
+func (r *fakeSessionRepository) GetSession(_ context.Context, id model.SessionID) (*model.Session, error) {
+	r.calls++
+	s, ok := r.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("session %s: %w", id, model.ErrNotFound)
+	}
+	return &s, nil
+}
+
+// unavailableConversationRepository fails every read, as during a history store outage.
+type unavailableConversationRepository struct {
+	repository.ConversationRepository
+}
+
+func (unavailableConversationRepository) ListMessages(context.Context, model.SessionID) ([]model.Message, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func TestContinueSession(t *testing.T) {
+	conversations := infrastructure.NewConversationMemoryRepository()
+	if err := conversations.AppendMessages(context.Background(),
+		model.Message{ID: "1", SessionID: "implicit-alice", UserID: "alice", Role: model.MessageRoleUser},
+		model.Message{ID: "2", SessionID: "implicit-bob", UserID: "bob", Role: model.MessageRoleUser},
+	); err != nil {
+		t.Fatal(err)
+	}
+	sessions := map[model.SessionID]model.Session{
+		"active-alice": {ID: "active-alice", UserID: "alice", Status: model.SessionStatusActive},
+		"ended-alice":  {ID: "ended-alice", UserID: "alice", Status: model.SessionStatusEnded},
+		"active-bob":   {ID: "active-bob", UserID: "bob", Status: model.SessionStatusActive},
+	}
+	alice := model.WithIdentity(context.Background(), &model.Identity{Subject: "alice"})
+
+	tests := []struct {
+		name          string
+		sessionAPI    bool
+		conversations repository.ConversationRepository
+		ctx           context.Context
+		id            model.SessionID
+		wantErr       error
+	}{
+		{name: "new session", sessionAPI: true, ctx: alice, id: ""},
+		{name: "own active session", sessionAPI: true, ctx: alice, id: "active-alice"},
+		{name: "own ended session", sessionAPI: true, ctx: alice, id: "ended-alice", wantErr: model.ErrConflict},
+		{name: "other user's session", sessionAPI: true, ctx: alice, id: "active-bob", wantErr: model.ErrNotFound},
+		{name: "own session from history", sessionAPI: true, ctx: alice, id: "implicit-alice"},
+		{name: "other user's session from history", sessionAPI: true, ctx: alice, id: "implicit-bob", wantErr: model.ErrNotFound},
+		// 履歴が失われたセッション（再起動・別レプリカ）は Bedrock に判断を委ねる
+		{name: "unknown session", sessionAPI: true, ctx: alice, id: "unknown"},
+		{name: "unauthenticated", sessionAPI: true, ctx: context.Background(), id: "active-bob"},
+		{name: "history store outage", sessionAPI: true, conversations: unavailableConversationRepository{}, ctx: alice, id: "implicit-bob"},
+		{name: "session api disabled, own history", ctx: alice, id: "implicit-alice"},
+		{name: "session api disabled, other user's history", ctx: alice, id: "implicit-bob", wantErr: model.ErrNotFound},
+		{name: "session api disabled, unknown session", ctx: alice, id: "ended-alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessionRepository := &fakeSessionRepository{sessions: sessions}
+			conversationRepository := tt.conversations
+			if conversationRepository == nil {
+				conversationRepository = conversations
+			}
+			u := NewSessionUsecase(&config.Config{SessionAPIEnabled: tt.sessionAPI}, sessionRepository, conversationRepository)
+			err := u.ContinueSession(tt.ctx, tt.id)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("ContinueSession(%q) = %v, want nil", tt.id, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ContinueSession(%q) = %v, want %v", tt.id, err, tt.wantErr)
+			}
+			// セッション管理 API を使わない構成では Bedrock に問い合わせない
+			if !tt.sessionAPI && sessionRepository.calls > 0 {
+				t.Errorf("GetSession called %d times with the session API disabled", sessionRepository.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package usecase
+
+import (
+	"aws-s3-knowledge-chatbot/backend/internal/domain/model"
+	"aws-s3-knowledge-chatbot/backend/internal/transport/http/sse"
+	"context"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+)
+
+// estimateUsage estimates the usage of an invocation from character counts. The input counts the query,
+// the prompt template and the cited chunks; chunks retrieved but not cited are not visible to us, so the
+// input is a lower bound.
+func (u *bedrockAgentRuntimeUsecase) estimateUsage(query string, opts model.InvokeOptions, refs []sse.CitationReference, outputCharacters int) model.Usage {
+	input := utf8.RuneCountInString(query)
+	if opts.PromptTemplate != nil {
+		input += utf8.RuneCountInString(opts.PromptTemplate.Text)
+	}
+	for _, ref := range refs {
+		input += utf8.RuneCountInString(ref.Text)
+	}
+	// 価格表に無いモデルはコスト 0 としてトークン数のみ記録する
+	price, _ := u.config.GetModelPrice(opts.ResolvedModelArn())
+	return model.NewUsage(int64(input), int64(outputCharacters), u.config.CharactersPerToken, price)
+}
+
+// recordUsage sends the record to the usage sink, attributing it to the caller in ctx.
+// Failures are logged only so that accounting never breaks answering.
+func (u *bedrockAgentRuntimeUsecase) recordUsage(ctx context.Context, record model.UsageRecord) {
+	if identity := model.IdentityFromContext(ctx); identity != nil {
+		record.UserID = identity.Subject
+		record.TenantID = identity.TenantID
+	}
+	record.CreatedAt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := u.usageRepository.Record(ctx, record); err != nil {
+		slog.ErrorContext(ctx, "record usage failed", "message_id", record.MessageID, "error", err)
+	}
+}
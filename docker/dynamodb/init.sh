@@ -0,0 +1,41 @@
+#!/bin/sh
+# DynamoDB Local に会話履歴・利用量のテーブルを作成する（infra/modules/dynamodb と同じ構成）
+set -eu
+
+endpoint="${DYNAMODB_ENDPOINT:-http://dynamodb-local:8000}"
+conversation_table="${CONVERSATION_TABLE:-conversations}"
+conversation_user_index="${CONVERSATION_USER_INDEX:-user_id-index}"
+usage_table="${USAGE_TABLE:-usage}"
+
+ddb() {
+  aws dynamodb --endpoint-url "$endpoint" "$@"
+}
+
+exists() {
+  ddb describe-table --table-name "$1" >/dev/null 2>&1
+}
+
+if exists "$conversation_table"; then
+  echo "table $conversation_table already exists"
+else
+  ddb create-table \
+    --table-name "$conversation_table" \
+    --billing-mode PAY_PER_REQUEST \
+    --attribute-definitions AttributeName=pk,AttributeType=S AttributeName=sk,AttributeType=S AttributeName=user_id,AttributeType=S \
+    --key-schema AttributeName=pk,KeyType=HASH AttributeName=sk,KeyType=RANGE \
+    --global-secondary-indexes "IndexName=$conversation_user_index,KeySchema=[{AttributeName=user_id,KeyType=HASH}],Projection={ProjectionType=ALL}" \
+    >/dev/null
+  echo "created table $conversation_table"
+fi
+
+if exists "$usage_table"; then
+  echo "table $usage_table already exists"
+else
+  ddb create-table \
+    --table-name "$usage_table" \
+    --billing-mode PAY_PER_REQUEST \
+    --attribute-definitions AttributeName=pk,AttributeType=S AttributeName=sk,AttributeType=S \
+    --key-schema AttributeName=pk,KeyType=HASH AttributeName=sk,KeyType=RANGE \
+    >/dev/null
+  echo "created table $usage_table"
+fi
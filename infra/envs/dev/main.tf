@@ -8,6 +8,17 @@ module "iam" {
   current_account   = data.aws_caller_identity.me.account_id
   bucket_arn        = module.s3_knowledge.bucket_arn
   oss_collection_id = module.opensearch_serverless.collection_id
+
+  dynamodb_table_arns = [
+    module.dynamodb.conversation_table_arn,
+    module.dynamodb.usage_table_arn,
+  ]
+}
+
+module "dynamodb" {
+  source = "../../modules/dynamodb"
+
+  project = var.project
 }
 
 module "s3_knowledge" {
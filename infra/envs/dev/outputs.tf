@@ -6,6 +6,10 @@ output "main_knowledge_bucket_arn" {
   value = module.s3_knowledge.bucket_arn
 }
 
+# CONVERSATION_TABLE / USAGE_TABLE に指定する
+output "conversation_table_name" { value = module.dynamodb.conversation_table_name }
+output "usage_table_name" { value = module.dynamodb.usage_table_name }
+
 # debug用
 output "current_account" { value = data.aws_caller_identity.me.account_id }
 output "current_arn"     { value = data.aws_caller_identity.me.arn }
@@ -0,0 +1,51 @@
+# 会話履歴（CONVERSATION_STORE=dynamodb）
+# pk=session_id, sk="MSG#<message id>" | "META"。user_id の GSI でユーザー毎のセッション一覧を引く
+resource "aws_dynamodb_table" "conversations" {
+  name         = "${var.project}-conversations"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "pk"
+  range_key    = "sk"
+
+  attribute {
+    name = "pk"
+    type = "S"
+  }
+  attribute {
+    name = "sk"
+    type = "S"
+  }
+  attribute {
+    name = "user_id"
+    type = "S"
+  }
+
+  global_secondary_index {
+    name            = var.conversation_user_index
+    hash_key        = "user_id"
+    projection_type = "ALL"
+  }
+
+  point_in_time_recovery { enabled = true }
+  server_side_encryption { enabled = true }
+}
+
+# 利用量・コスト（USAGE_SINK=dynamodb）
+# pk="USER#<user_id>", sk="<created_at>#<message id>"
+resource "aws_dynamodb_table" "usage" {
+  name         = "${var.project}-usage"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "pk"
+  range_key    = "sk"
+
+  attribute {
+    name = "pk"
+    type = "S"
+  }
+  attribute {
+    name = "sk"
+    type = "S"
+  }
+
+  point_in_time_recovery { enabled = true }
+  server_side_encryption { enabled = true }
+}
@@ -0,0 +1,4 @@
+output "conversation_table_name" { value = aws_dynamodb_table.conversations.name }
+output "conversation_table_arn" { value = aws_dynamodb_table.conversations.arn }
+output "usage_table_name" { value = aws_dynamodb_table.usage.name }
+output "usage_table_arn" { value = aws_dynamodb_table.usage.arn }
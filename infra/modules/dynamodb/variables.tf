@@ -0,0 +1,5 @@
+variable "project" { type = string }
+variable "conversation_user_index" {
+  type    = string
+  default = "user_id-index" # CONVERSATION_USER_INDEX と合わせる
+}
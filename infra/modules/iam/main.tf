@@ -85,6 +85,7 @@ data "aws_iam_policy_document" "rag_runtime" {
     sid     = "KBReadRAG"
     effect  = "Allow"
     actions = [
+      "bedrock:Retrieve",
       "bedrock:RetrieveAndGenerate",
       "bedrock:RetrieveAndGenerateStream",
     ]
@@ -100,6 +101,45 @@ data "aws_iam_policy_document" "rag_runtime" {
     ]
     resources = ["*"] # 後で foundation-model ARN に絞るとより安全
   }
+
+  # セッション管理 API（/sessions）
+  statement {
+    sid     = "ManageAgentSessions"
+    effect  = "Allow"
+    actions = [
+      "bedrock:CreateSession",
+      "bedrock:GetSession",
+      "bedrock:EndSession",
+      "bedrock:DeleteSession",
+      "bedrock:ListSessions",
+    ]
+    resources = ["*"]
+  }
+
+  # 会話履歴・利用量の保存（CONVERSATION_STORE=dynamodb, USAGE_SINK=dynamodb）
+  statement {
+    sid     = "ConversationAndUsageTables"
+    effect  = "Allow"
+    actions = [
+      "dynamodb:PutItem",
+      "dynamodb:UpdateItem",
+      "dynamodb:DeleteItem",
+      "dynamodb:Query",
+      "dynamodb:Scan",
+    ]
+    resources = concat(
+      var.dynamodb_table_arns,
+      [for arn in var.dynamodb_table_arns : "${arn}/index/*"],
+    )
+  }
+
+  # 引用元ドキュメントの署名付きURL発行（CITATION_PRESIGN_URLS）
+  statement {
+    sid       = "PresignCitationDocuments"
+    effect    = "Allow"
+    actions   = ["s3:GetObject"]
+    resources = ["${var.bucket_arn}/*"]
+  }
 }
 
 resource "aws_iam_policy" "rag_runtime" {
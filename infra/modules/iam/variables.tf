@@ -3,3 +3,4 @@ variable "region" {}
 variable "current_account" {}
 variable "bucket_arn" {}
 variable "oss_collection_id" {}
+variable "dynamodb_table_arns" { type = list(string) }